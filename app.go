@@ -1,18 +1,52 @@
 package helios
 
 import (
-	"net/http"
-	"os"
+	"context"
+	"io"
+	"log"
+	"net"
+	"reflect"
+	"sync"
 
-	"github.com/gorilla/sessions"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite" // use sqlite dialect
 )
 
 // Helios is the core of the apps
 type Helios struct {
-	models []interface{}
-	store  *sessions.CookieStore
+	models            []interface{}
+	store             SessionStore
+	dbConfig          DatabaseConfig
+	databases         map[string]*gorm.DB
+	dbMutex           sync.RWMutex
+	dbRouter          DatabaseRouter
+	router            *Router
+	urlParamExtractor URLParamExtractor
+	debug             bool
+	secretKeys        [][]byte
+	encryptionKey     []byte
+	migrations        []Migration
+	commands          map[string]Command
+	out               io.Writer
+	logger            *log.Logger
+	validators        map[string]Validator
+	validations       map[reflect.Type]parsedValidations
+	validationMutex   sync.RWMutex
+	multipartConfig   *MultipartConfig
+	codecs            map[string]Codec
+	codecOrder        []string
+	jsonOptions       JSONOptions
+	passwordHasher    PasswordHasher
+	authBackends      []AuthBackend
+	policies          map[reflect.Type]Policy
+	rateLimitStore    RateLimitStore
+	trustedProxies    []*net.IPNet
+	serverConfig      *ServerConfig
+	startHooks        []func() error
+	shutdownHooks     []func(ctx context.Context) error
+	serverMutex       sync.Mutex
+	stopServer        chan struct{}
+	serverDone        chan struct{}
 }
 
 // App will be the core app that has all the models
@@ -23,26 +57,78 @@ var App Helios
 // directly for ORM and database
 var DB *gorm.DB
 
-// Initialize the database to production database
+// Initialize the database to production database.
+// The database configuration is taken from SetDatabaseConfig, the empty
+// fields are filled from environment variables (see DatabaseConfigFromEnv),
+// and finally it falls back to sqlite3 with db.sqlite3 file. Other dialect
+// without url returns ErrDatabaseURLEmpty.
+// Outside debug mode, it refuses weak secret keys, and empty secret key
+// if the default cookie session store or encryption key is used.
 func (app *Helios) Initialize() error {
-	var err error
-	DB, err = gorm.Open("sqlite3", "db.sqlite3")
+	app.loadSecretFromEnv()
+	if err := app.validateSecret(); err != nil {
+		return err
+	}
+
+	config, err := app.databaseConfig()
+	if err != nil {
+		return err
+	}
+	DB, err = OpenDatabase(config)
 	if err != nil {
 		return err
 	}
-	key := []byte(os.Getenv("HELIOS_SECRET"))
-	app.store = sessions.NewCookieStore(key)
+	if app.store == nil {
+		store := NewCookieSessionStore(app.secretKeys...)
+		if len(app.encryptionKey) > 0 {
+			if err = store.SetEncryptionKey(app.encryptionKey); err != nil {
+				return err
+			}
+		}
+		app.store = store
+	}
 	return nil
 }
 
+// databaseConfig returns the database config from SetDatabaseConfig,
+// filled by environment variables. The default url is only used for
+// sqlite3, other dialects need their url to be set.
+func (app *Helios) databaseConfig() (DatabaseConfig, error) {
+	envConfig, err := DatabaseConfigFromEnv()
+	if err != nil {
+		return envConfig, err
+	}
+	config := app.dbConfig.merge(envConfig)
+	if config.Dialect == "" {
+		config.Dialect = defaultDatabaseDialect
+	}
+	if config.URL == "" {
+		if config.Dialect != defaultDatabaseDialect {
+			return config, ErrDatabaseURLEmpty
+		}
+		config.URL = defaultDatabaseURL
+	}
+	return config, nil
+}
+
+// SetDatabaseConfig sets the configuration used by Initialize
+// to open the database connection
+func (app *Helios) SetDatabaseConfig(config DatabaseConfig) {
+	app.dbConfig = config
+}
+
 // RegisterModel so the database will be migrated
 func (app *Helios) RegisterModel(model interface{}) {
 	app.models = append(app.models, model)
 }
 
-// CloseDB close the database connection
+// CloseDB close all the database connections
 func (app *Helios) CloseDB() {
-	DB.Close()
+	app.closeDatabases()
+	if DB != nil {
+		DB.Close()
+		DB = nil
+	}
 }
 
 // Migrate migrate all the models
@@ -53,8 +139,12 @@ func (app *Helios) Migrate() {
 }
 
 // BeforeTest has to be called everytime a test is run
-// It will reset the database
+// It will reset the database, and use in-memory session store
+// if there is no session store yet
 func (app *Helios) BeforeTest() {
+	if app.store == nil {
+		app.store = NewMemorySessionStore()
+	}
 	if DB == nil {
 		var err error
 		DB, err = gorm.Open("sqlite3", ":memory:")
@@ -68,8 +158,3 @@ func (app *Helios) BeforeTest() {
 		}
 	}
 }
-
-func (app *Helios) getSession(r *http.Request) *sessions.Session {
-	session, _ := app.store.Get(r, os.Getenv("SESSION_NAME"))
-	return session
-}
@@ -0,0 +1,227 @@
+package helios
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// User is the built-in user model. It has to be registered with
+// App.RegisterModel to be migrated. Password is the hash of the password,
+// set it with SetPassword.
+type User struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	Username  string     `gorm:"unique_index;size:150;not null" json:"username"`
+	Email     string     `gorm:"size:254" json:"email"`
+	Password  string     `json:"-"`
+	IsActive  bool       `json:"isActive"`
+	LastLogin *time.Time `json:"lastLogin"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+
+	// IsSuperuser grants every permission and passes every policy
+	IsSuperuser bool         `json:"isSuperuser"`
+	Groups      []Group      `gorm:"many2many:helios_user_groups" json:"-"`
+	Permissions []Permission `gorm:"many2many:helios_user_permissions" json:"-"`
+}
+
+// TableName returns the table name of User
+func (User) TableName() string {
+	return "helios_users"
+}
+
+// NewUser returns new active user with hashed password.
+// The user is not saved to the database.
+func NewUser(username string, email string, password string) (*User, error) {
+	user := &User{Username: username, Email: email, IsActive: true}
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetPassword hashes password with the App password hasher and
+// sets it to user. The user has to be saved afterwards.
+func (user *User) SetPassword(password string) error {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	return nil
+}
+
+// CheckPassword returns true if password matches the password of user
+func (user *User) CheckPassword(password string) bool {
+	return CheckPassword(user.Password, password)
+}
+
+// AuthBackend identifies the user of request. It returns nil user and nil
+// error if the request doesn't carry its credentials, so the next backend
+// is tried, and non-nil error if the credentials are invalid.
+type AuthBackend interface {
+	Authenticate(req Request) (*User, error)
+}
+
+// AuthBackendFunc is an adapter to use ordinary function as AuthBackend
+type AuthBackendFunc func(req Request) (*User, error)
+
+// Authenticate calls f(req)
+func (f AuthBackendFunc) Authenticate(req Request) (*User, error) {
+	return f(req)
+}
+
+const sessionUserIDKey = "_auth_user_id"
+
+const userContextKey = "helios-user"
+
+// SessionAuthBackend identifies the user by the id stored in session by Login
+type SessionAuthBackend struct{}
+
+// Authenticate returns the active user whose id is in the session
+func (SessionAuthBackend) Authenticate(req Request) (*User, error) {
+	id, ok := req.GetSessionData(sessionUserIDKey).(uint)
+	if !ok {
+		return nil, nil
+	}
+	var user User
+	if err := req.DB().Where("id = ?", id).First(&user).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// SetAuthBackends sets the backends tried in order to identify the user
+// of request. By default, only SessionAuthBackend is used.
+//     helios.App.SetAuthBackends(helios.SessionAuthBackend{}, myHeaderBackend)
+func (app *Helios) SetAuthBackends(backends ...AuthBackend) {
+	app.authBackends = backends
+}
+
+func (app *Helios) authBackendList() []AuthBackend {
+	if app.authBackends == nil {
+		return []AuthBackend{SessionAuthBackend{}}
+	}
+	return app.authBackends
+}
+
+// ErrUnauthorized returned when the request requires authenticated user
+var ErrUnauthorized = ErrorAPI{
+	StatusCode: http.StatusUnauthorized,
+	Code:       "unauthorized",
+	Message:    "Authentication credentials were not provided or are invalid",
+}
+
+// ErrInvalidCredentials returned by Authenticate when the
+// username or the password is wrong
+var ErrInvalidCredentials = ErrorAPI{
+	StatusCode: http.StatusUnauthorized,
+	Code:       "invalid_credentials",
+	Message:    "Username or password is incorrect",
+}
+
+// Authenticate returns the active user with the username and password, or
+// ErrInvalidCredentials. It doesn't log the user in, see Login.
+func Authenticate(req Request, username string, password string) (*User, Error) {
+	var user User
+	if err := req.DB().Where("username = ?", username).First(&user).Error; err != nil {
+		if !gorm.IsRecordNotFoundError(err) {
+			return nil, ToError(err)
+		}
+		// hash anyway so the response time doesn't reveal the username exists
+		HashPassword(password) // nolint:errcheck
+		return nil, ErrInvalidCredentials
+	}
+	if !user.CheckPassword(password) || !user.IsActive {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+// ErrUserNotSaved returned by Login when the user doesn't have id
+var ErrUserNotSaved = errors.New("user is not saved")
+
+// Login stores the user in a new session, so the session id issued
+// before login can't be used, and updates the last login of user.
+// The user has to be saved first, otherwise ErrUserNotSaved is returned.
+// The error of saving the session is returned as well.
+func Login(req Request, user *User) error {
+	if user.ID == 0 {
+		// updating model without primary key updates every row
+		return ErrUserNotSaved
+	}
+	now := time.Now()
+	if err := req.DB().Model(user).Update("last_login", &now).Error; err != nil {
+		return err
+	}
+	req.RegenerateSession()
+	req.SetSessionData(sessionUserIDKey, user.ID)
+	if err := req.SaveSession(); err != nil {
+		return err
+	}
+	req.SetContextData(userContextKey, user)
+	return nil
+}
+
+// Logout destroys the session of request
+func Logout(req Request) {
+	req.DestroySession()
+	req.SetContextData(userContextKey, nil)
+}
+
+// CurrentUser returns the user of request identified by the App auth backends,
+// or nil if the request is anonymous. The result is cached in context data.
+func CurrentUser(req Request) *User {
+	user, _ := currentUser(req)
+	return user
+}
+
+func currentUser(req Request) (*User, error) {
+	if user, ok := req.GetContextData(userContextKey).(*User); ok && user != nil {
+		return user, nil
+	}
+	for _, backend := range App.authBackendList() {
+		user, err := backend.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			req.SetContextData(userContextKey, user)
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+// SetUser authenticates the request as user, so handlers and middlewares
+// using CurrentUser, RequireAuth, or RequirePermission see the user
+func (req *MockRequest) SetUser(user *User) {
+	req.SetSessionData(sessionUserIDKey, user.ID)
+	req.SetContextData(userContextKey, user)
+}
+
+// RequireAuth is middleware that loads the user of request with the App auth
+// backends into context data, retrievable by CurrentUser, and sends
+// ErrUnauthorized if the request is anonymous or the credentials are invalid.
+var RequireAuth Middleware = func(f HTTPHandler) HTTPHandler {
+	return func(req Request) {
+		user, err := currentUser(req)
+		if err != nil {
+			req.SendError(ToError(err))
+			return
+		}
+		if user == nil {
+			req.SendError(ErrUnauthorized)
+			return
+		}
+		f(req)
+	}
+}
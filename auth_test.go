@@ -0,0 +1,180 @@
+package helios
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAuthTest creates alice with fast password hasher, and returns
+// the teardown that restores the password hasher
+func setupAuthTest(t *testing.T) (*User, func()) {
+	App.BeforeTest()
+	App.SetPasswordHasher(BcryptHasher{Cost: 4})
+	DB.AutoMigrate(User{})
+	DB.Delete(User{})
+
+	user, err := NewUser("alice", "alice@example.com", "secret-password")
+	assert.Nil(t, err, "Failed to create user")
+	DB.Create(user)
+	return user, func() { App.SetPasswordHasher(nil) }
+}
+
+func TestAuthenticate(t *testing.T) {
+	user, teardown := setupAuthTest(t)
+	defer teardown()
+	DB.Create(&User{Username: "bob", Password: user.Password})
+
+	type authenticateTestCase struct {
+		username      string
+		password      string
+		expectedError Error
+	}
+	testCases := []authenticateTestCase{
+		{username: "alice", password: "secret-password", expectedError: nil},
+		{username: "alice", password: "wrong-password", expectedError: ErrInvalidCredentials},
+		{username: "carol", password: "secret-password", expectedError: ErrInvalidCredentials},
+		{username: "bob", password: "secret-password", expectedError: ErrInvalidCredentials},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		authenticated, err := Authenticate(&req, testCase.username, testCase.password)
+		assert.Equal(t, testCase.expectedError, err, "Unexpected error on test case %d", i)
+		if testCase.expectedError == nil {
+			assert.Equal(t, user.ID, authenticated.ID, "Unexpected user on test case %d", i)
+		}
+	}
+}
+
+func TestLoginLogout(t *testing.T) {
+	user, teardown := setupAuthTest(t)
+	defer teardown()
+
+	req := NewMockRequest()
+	assert.Nil(t, CurrentUser(&req), "Request should be anonymous before login")
+
+	assert.Nil(t, Login(&req, user), "Failed to login")
+	assert.True(t, req.SessionRegenerated, "Session should be regenerated on login")
+	assert.Equal(t, user.ID, req.SessionData[sessionUserIDKey], "User id should be stored in session")
+	assert.NotNil(t, user.LastLogin, "Last login should be updated")
+
+	nextReq := NewMockRequest()
+	nextReq.SessionData = req.SessionData
+	assert.Equal(t, "alice", CurrentUser(&nextReq).Username, "User should be loaded from session")
+
+	Logout(&nextReq)
+	assert.True(t, nextReq.SessionDestroyed, "Session should be destroyed on logout")
+	assert.Nil(t, CurrentUser(&nextReq), "Request should be anonymous after logout")
+
+	DB.Create(&User{Username: "bob", Email: "bob@example.com"})
+	unsavedReq := NewMockRequest()
+	assert.Equal(t, ErrUserNotSaved, Login(&unsavedReq, &User{Username: "carol"}), "Unsaved user should not be logged in")
+	var count int
+	DB.Model(User{}).Where("last_login IS NOT NULL").Count(&count)
+	assert.Equal(t, 1, count, "Last login of other users should not be updated")
+	assert.Nil(t, unsavedReq.SessionData[sessionUserIDKey], "Unsaved user should not be stored in session")
+
+	DB.Model(user).Update("is_active", false)
+	inactiveReq := NewMockRequest()
+	inactiveReq.SessionData[sessionUserIDKey] = user.ID
+	assert.Nil(t, CurrentUser(&inactiveReq), "Inactive user should not be loaded")
+}
+
+func TestRequireAuth(t *testing.T) {
+	user, teardown := setupAuthTest(t)
+	defer teardown()
+	defer App.SetAuthBackends()
+	defer App.SetLogger(nil)
+	App.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	handler := RequireAuth(func(req Request) {
+		req.SendJSON(CurrentUser(req).Username, http.StatusOK)
+	})
+	errInvalidToken := ErrorAPI{StatusCode: http.StatusUnauthorized, Code: "invalid_token", Message: "Invalid token"}
+	headerBackend := AuthBackendFunc(func(req Request) (*User, error) {
+		switch req.GetHeader("X-User") {
+		case "":
+			return nil, nil
+		case "alice":
+			return user, nil
+		case "broken":
+			return nil, errors.New("backend is down")
+		}
+		return nil, errInvalidToken
+	})
+	App.SetAuthBackends(headerBackend, SessionAuthBackend{})
+
+	type requireAuthTestCase struct {
+		header           string
+		sessionUserID    interface{}
+		expectedCode     int
+		expectedResponse string
+	}
+	testCases := []requireAuthTestCase{
+		{expectedCode: http.StatusUnauthorized, expectedResponse: `{"code":"unauthorized","message":"Authentication credentials were not provided or are invalid"}`},
+		{sessionUserID: user.ID, expectedCode: http.StatusOK, expectedResponse: `"alice"`},
+		{sessionUserID: user.ID + 1, expectedCode: http.StatusUnauthorized, expectedResponse: `{"code":"unauthorized","message":"Authentication credentials were not provided or are invalid"}`},
+		{header: "alice", expectedCode: http.StatusOK, expectedResponse: `"alice"`},
+		{header: "mallory", sessionUserID: user.ID, expectedCode: http.StatusUnauthorized, expectedResponse: `{"code":"invalid_token","message":"Invalid token"}`},
+		{header: "broken", expectedCode: http.StatusInternalServerError, expectedResponse: `{"code":"internal_server_error","message":"Error occured while processing the request"}`},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		if testCase.header != "" {
+			req.RequestHeader["x-user"] = testCase.header
+		}
+		if testCase.sessionUserID != nil {
+			req.SessionData[sessionUserIDKey] = testCase.sessionUserID
+		}
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+		assert.Equal(t, testCase.expectedResponse, string(req.JSONResponse), "Unexpected response on test case %d", i)
+	}
+}
+
+func TestLoginWithSessionStore(t *testing.T) {
+	user, teardown := setupAuthTest(t)
+	defer teardown()
+
+	router := NewRouter()
+	router.POST("/login", func(req Request) {
+		authenticated, err := Authenticate(req, "alice", "secret-password")
+		if err != nil {
+			req.SendError(err)
+			return
+		}
+		if err := Login(req, authenticated); err != nil {
+			req.SendError(ToError(err))
+			return
+		}
+		req.SendJSON("ok", http.StatusOK)
+	})
+	router.GET("/me", func(req Request) {
+		req.SendJSON(CurrentUser(req).ID, http.StatusOK)
+	}, RequireAuth)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "Anonymous request should be unauthorized")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", "/login", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Failed to login")
+	cookies := recorder.Result().Cookies()
+	assert.NotEmpty(t, cookies, "Session cookie should be set on login")
+
+	request := httptest.NewRequest("GET", "/me", nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Logged in request should be authorized")
+	assert.Equal(t, fmt.Sprint(user.ID), recorder.Body.String(), "Unexpected user id")
+}
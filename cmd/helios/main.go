@@ -0,0 +1,31 @@
+// Command helios manages Helios project using the same configuration
+// as helios.App.Initialize, i.e. the HELIOS_* environment variables.
+//
+// Usage:
+//     helios migrate [up|down|status] [--dir migrations]
+//     helios runserver [--addr :8000]
+//     helios routes
+//     helios createsecret
+//     helios dbshell
+//     helios createtoken --user alice [--name ci] [--scopes read,write] [--ttl 720h]
+//     helios revoketoken --id 3
+//
+// Apps that register their own models, migrations, and routes
+// should call helios.App.RunCommand(os.Args) from their main instead.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	_ "github.com/jinzhu/gorm/dialects/mysql"    // use mysql dialect
+	_ "github.com/jinzhu/gorm/dialects/postgres" // use postgres dialect
+	"github.com/yonasadiel/helios"
+)
+
+func main() {
+	if err := helios.App.RunCommand(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,397 @@
+package helios
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack"
+)
+
+// Codec encodes response body and decodes request body of a media type
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// JSONOptions is the options of decoding json request body
+type JSONOptions struct {
+	// DisallowUnknownFields rejects field that doesn't exist on the struct
+	DisallowUnknownFields bool
+
+	// UseNumber decodes number into interface{} as json.Number
+	// instead of float64
+	UseNumber bool
+
+	// DisallowTrailingData rejects body with data after the json value
+	DisallowTrailingData bool
+
+	// MaxBodySize is the maximum bytes of the body,
+	// ErrRequestTooLarge is returned if it is exceeded
+	MaxBodySize int64
+}
+
+// SetJSONOptions sets the options of the built-in json codec
+func (app *Helios) SetJSONOptions(options JSONOptions) {
+	app.jsonOptions = options
+}
+
+// JSONCodec encodes and decodes application/json
+type JSONCodec struct {
+	Options JSONOptions
+}
+
+// Encode writes v as json
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Decode reads json into v. Value with wrong type and unknown field are
+// returned as ErrorForm, and body larger than MaxBodySize is returned as
+// ErrRequestTooLarge.
+func (codec JSONCodec) Decode(r io.Reader, v interface{}) error {
+	limited := &io.LimitedReader{R: r, N: codec.Options.MaxBodySize + 1}
+	if codec.Options.MaxBodySize > 0 {
+		r = limited
+	}
+	decoder := json.NewDecoder(r)
+	if codec.Options.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if codec.Options.UseNumber {
+		decoder.UseNumber()
+	}
+
+	err := decoder.Decode(v)
+	if err == nil && codec.Options.DisallowTrailingData {
+		if _, tokenErr := decoder.Token(); tokenErr != io.EOF {
+			err = errors.New("json: trailing data after value")
+		}
+	}
+	if codec.Options.MaxBodySize > 0 {
+		io.Copy(ioutil.Discard, limited) // nolint:errcheck
+		if limited.N <= 0 {
+			return ErrRequestTooLarge
+		}
+	}
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+	return nil
+}
+
+// jsonUnknownFieldPrefix is the prefix of the error returned by json.Decoder
+// for unknown field when DisallowUnknownFields is set
+const jsonUnknownFieldPrefix = "json: unknown field "
+
+// jsonDecodeError maps the error of json decoder to ErrorForm if it
+// can be attributed to a field, or to ErrJSONParseFailed otherwise
+func jsonDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fieldFormError(strings.Split(typeErr.Field, "."), "must be "+jsonTypeName(typeErr.Type))
+	}
+	// encoding/json has no typed error for unknown field, its message is
+	// pinned by TestJSONUnknownFieldMessage
+	if strings.HasPrefix(err.Error(), jsonUnknownFieldPrefix) {
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), jsonUnknownFieldPrefix))
+		if unquoteErr == nil {
+			formErr := NewErrorForm()
+			formErr.FieldError[field] = ErrorFormFieldAtomic{"unknown field"}
+			return formErr
+		}
+	}
+	return ErrJSONParseFailed
+}
+
+// fieldFormError returns ErrorForm with message on the field at path
+func fieldFormError(path []string, message string) ErrorForm {
+	formErr := NewErrorForm()
+	fieldErr := formErr.FieldError
+	for _, name := range path[:len(path)-1] {
+		nested := make(ErrorFormFieldNested)
+		fieldErr[name] = nested
+		fieldErr = nested
+	}
+	fieldErr[path[len(path)-1]] = ErrorFormFieldAtomic{message}
+	return formErr
+}
+
+// jsonTypeName returns the name of json type that can be decoded to t
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
+
+// XMLCodec encodes and decodes application/xml.
+// Note that encoding/xml doesn't support map, e.g. the message of Error.
+type XMLCodec struct{}
+
+// Encode writes v as xml
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// Decode reads xml into v
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// MessagePackCodec encodes and decodes application/msgpack,
+// the fields are named by json tag
+type MessagePackCodec struct{}
+
+// Encode writes v as msgpack
+func (MessagePackCodec) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).UseJSONTag(true).Encode(v)
+}
+
+// Decode reads msgpack into v
+func (MessagePackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).UseJSONTag(true).Decode(v)
+}
+
+// CBORCodec encodes and decodes application/cbor,
+// the fields are named by cbor tag, or json tag if there is no cbor tag
+type CBORCodec struct{}
+
+// Encode writes v as cbor
+func (CBORCodec) Encode(w io.Writer, v interface{}) error {
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Decode reads cbor into v
+func (CBORCodec) Decode(r io.Reader, v interface{}) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return cbor.Unmarshal(data, v)
+}
+
+type registeredCodec struct {
+	mediaType string
+	codec     Codec
+}
+
+// builtinCodecs is ordered by preference,
+// the first one is used if the client accepts anything
+func (app *Helios) builtinCodecs() []registeredCodec {
+	return []registeredCodec{
+		{"application/json", JSONCodec{Options: app.jsonOptions}},
+		{"application/xml", XMLCodec{}},
+		{"text/xml", XMLCodec{}},
+		{"application/msgpack", MessagePackCodec{}},
+		{"application/x-msgpack", MessagePackCodec{}},
+		{"application/cbor", CBORCodec{}},
+	}
+}
+
+// RegisterCodec adds codec for the media type, e.g. application/yaml.
+// Codec with the same media type as built-in replaces it. The built-in
+// codecs are json, xml, msgpack, and cbor.
+func (app *Helios) RegisterCodec(mediaType string, codec Codec) {
+	if app.codecs == nil {
+		app.codecs = make(map[string]Codec)
+		app.codecOrder = make([]string, 0)
+	}
+	mediaType = strings.ToLower(mediaType)
+	if _, ok := app.codecs[mediaType]; !ok {
+		app.codecOrder = append(app.codecOrder, mediaType)
+	}
+	app.codecs[mediaType] = codec
+}
+
+// codecList returns the built-in codecs replaced by the registered one
+// with the same media type, followed by the other registered codecs
+func (app *Helios) codecList() []registeredCodec {
+	codecs := app.builtinCodecs()
+	builtin := make(map[string]bool)
+	for i, c := range codecs {
+		builtin[c.mediaType] = true
+		if codec, ok := app.codecs[c.mediaType]; ok {
+			codecs[i].codec = codec
+		}
+	}
+	for _, mediaType := range app.codecOrder {
+		if !builtin[mediaType] {
+			codecs = append(codecs, registeredCodec{mediaType, app.codecs[mediaType]})
+		}
+	}
+	return codecs
+}
+
+// codecFor returns the codec of the media type. Media type with structured
+// syntax suffix, e.g. application/problem+json, uses the codec of the suffix.
+func (app *Helios) codecFor(mediaType string) (Codec, bool) {
+	mediaType = strings.ToLower(mediaType)
+	codecs := app.codecList()
+	for _, c := range codecs {
+		if c.mediaType == mediaType {
+			return c.codec, true
+		}
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		suffix := "application/" + mediaType[i+1:]
+		for _, c := range codecs {
+			if c.mediaType == suffix {
+				return c.codec, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// codecForContentType returns the codec to decode body with the
+// content type, json is used if the content type is empty
+func (app *Helios) codecForContentType(contentType string) (Codec, string, bool) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, "", false
+		}
+	}
+	codec, ok := app.codecFor(mediaType)
+	return codec, mediaType, ok
+}
+
+type acceptRange struct {
+	mediaType   string
+	q           float64
+	specificity int
+}
+
+// parseAccept returns the media ranges of accept header,
+// ordered by q value and then by specificity
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		specificity := 2
+		if mediaType == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(mediaType, "/*") {
+			specificity = 1
+		}
+		ranges = append(ranges, acceptRange{mediaType, q, specificity})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity > ranges[j].specificity
+	})
+	return ranges
+}
+
+func (r acceptRange) matches(mediaType string) bool {
+	switch r.specificity {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*"))
+	default:
+		return r.mediaType == mediaType
+	}
+}
+
+// quality returns the q value of the most specific range matching mediaType
+func quality(ranges []acceptRange, mediaType string) float64 {
+	best := -1
+	q := 0.0
+	for _, r := range ranges {
+		if r.matches(mediaType) && r.specificity > best {
+			best, q = r.specificity, r.q
+		}
+	}
+	return q
+}
+
+// negotiateCodec returns the codec and the media type of response
+// chosen from accept header, json is used if the header is empty
+func (app *Helios) negotiateCodec(accept string) (Codec, string, bool) {
+	codecs := app.codecList()
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0].codec, codecs[0].mediaType, true
+	}
+	ranges := parseAccept(accept)
+	for _, r := range ranges {
+		if r.q <= 0 {
+			break
+		}
+		if r.specificity < 2 {
+			for _, c := range codecs {
+				if r.matches(c.mediaType) && quality(ranges, c.mediaType) > 0 {
+					return c.codec, c.mediaType, true
+				}
+			}
+			continue
+		}
+		if codec, ok := app.codecFor(r.mediaType); ok {
+			return codec, r.mediaType, true
+		}
+	}
+	return nil, "", false
+}
+
+// ErrNotAcceptable returned when none of the media types
+// in Accept header of the request can be sent
+var ErrNotAcceptable = ErrorAPI{
+	StatusCode: http.StatusNotAcceptable,
+	Code:       "not_acceptable",
+	Message:    "None of the accepted media types can be sent",
+}
+
+// ErrRequestParseFailed will be returned when calling req.DeserializeRequestData
+// but the body can't be decoded with the codec of its content type
+var ErrRequestParseFailed = ErrorAPI{
+	StatusCode: http.StatusBadRequest,
+	Code:       "failed_to_parse_request",
+	Message:    "Failed to parse request body",
+}
@@ -0,0 +1,228 @@
+package helios
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type codecTestData struct {
+	Name  string `json:"name" xml:"name"`
+	Count int    `json:"count" xml:"count"`
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	App.BeforeTest()
+
+	type negotiateTestCase struct {
+		accept            string
+		expectedMediaType string
+		expectedOK        bool
+	}
+	testCases := []negotiateTestCase{
+		{accept: "", expectedMediaType: "application/json", expectedOK: true},
+		{accept: "*/*", expectedMediaType: "application/json", expectedOK: true},
+		{accept: "application/xml", expectedMediaType: "application/xml", expectedOK: true},
+		{accept: "application/json;q=0.5, application/msgpack", expectedMediaType: "application/msgpack", expectedOK: true},
+		{accept: "application/json;q=0, */*;q=0.1", expectedMediaType: "application/xml", expectedOK: true},
+		{accept: "text/*, application/cbor;q=0.9", expectedMediaType: "text/xml", expectedOK: true},
+		{accept: "application/vnd.api+json", expectedMediaType: "application/vnd.api+json", expectedOK: true},
+		{accept: "text/html, image/png;q=0.8", expectedOK: false},
+		{accept: "application/json;q=0", expectedOK: false},
+	}
+	for i, testCase := range testCases {
+		_, mediaType, ok := App.negotiateCodec(testCase.accept)
+		assert.Equal(t, testCase.expectedOK, ok, "Different negotiation result on test case %d", i)
+		assert.Equal(t, testCase.expectedMediaType, mediaType, "Different media type on test case %d", i)
+	}
+}
+
+func TestSend(t *testing.T) {
+	App.BeforeTest()
+
+	data := codecTestData{Name: "helios", Count: 3}
+	type sendTestCase struct {
+		accept              string
+		expectedCode        int
+		expectedContentType string
+		decode              func([]byte, interface{}) error
+	}
+	testCases := []sendTestCase{{
+		accept:              "application/json",
+		expectedCode:        http.StatusOK,
+		expectedContentType: "application/json",
+		decode:              json.Unmarshal,
+	}, {
+		accept:              "application/xml",
+		expectedCode:        http.StatusOK,
+		expectedContentType: "application/xml",
+		decode:              func(b []byte, v interface{}) error { return XMLCodec{}.Decode(bytes.NewReader(b), v) },
+	}, {
+		accept:              "application/x-msgpack",
+		expectedCode:        http.StatusOK,
+		expectedContentType: "application/x-msgpack",
+		decode:              func(b []byte, v interface{}) error { return MessagePackCodec{}.Decode(bytes.NewReader(b), v) },
+	}, {
+		accept:              "application/cbor",
+		expectedCode:        http.StatusOK,
+		expectedContentType: "application/cbor",
+		decode:              cbor.Unmarshal,
+	}, {
+		accept:              "text/html",
+		expectedCode:        http.StatusNotAcceptable,
+		expectedContentType: "application/json",
+	}}
+	for i, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept", testCase.accept)
+		Handle(func(req Request) {
+			req.Send(data, http.StatusOK)
+		})(recorder, request)
+		assert.Equal(t, testCase.expectedCode, recorder.Code, "Different status code on test case %d", i)
+		assert.Equal(t, testCase.expectedContentType, recorder.Header().Get("Content-Type"), "Different content type on test case %d", i)
+		if testCase.decode != nil {
+			var actual codecTestData
+			assert.Nil(t, testCase.decode(recorder.Body.Bytes(), &actual), "Failed to decode response on test case %d", i)
+			assert.Equal(t, data, actual, "Different response on test case %d", i)
+		}
+	}
+
+	req := NewMockRequest()
+	req.RequestHeader["accept"] = "application/msgpack"
+	req.Send(data, http.StatusCreated)
+	var actual codecTestData
+	assert.Equal(t, http.StatusCreated, req.StatusCode, "Different status code of mock request")
+	assert.Equal(t, "application/msgpack", req.ResponseHeader["Content-Type"], "Different content type of mock request")
+	assert.Nil(t, MessagePackCodec{}.Decode(bytes.NewReader(req.Response), &actual), "Failed to decode mock response")
+	assert.Equal(t, data, actual, "Different mock response")
+}
+
+func TestSendError(t *testing.T) {
+	App.BeforeTest()
+
+	type sendErrorTestCase struct {
+		accept              string
+		expectedContentType string
+		decode              func([]byte, interface{}) error
+	}
+	testCases := []sendErrorTestCase{{
+		accept:              "",
+		expectedContentType: "application/json",
+		decode:              json.Unmarshal,
+	}, {
+		accept:              "application/msgpack",
+		expectedContentType: "application/msgpack",
+		decode:              func(b []byte, v interface{}) error { return MessagePackCodec{}.Decode(bytes.NewReader(b), v) },
+	}, {
+		accept:              "application/cbor",
+		expectedContentType: "application/cbor",
+		decode:              cbor.Unmarshal,
+	}, {
+		accept:              "application/xml",
+		expectedContentType: "application/json",
+		decode:              json.Unmarshal,
+	}, {
+		accept:              "text/html",
+		expectedContentType: "application/json",
+		decode:              json.Unmarshal,
+	}}
+	for i, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept", testCase.accept)
+		Handle(func(req Request) {
+			req.SendError(ErrNotFound)
+		})(recorder, request)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Different status code on test case %d", i)
+		assert.Equal(t, testCase.expectedContentType, recorder.Header().Get("Content-Type"), "Different content type on test case %d", i)
+		var actual map[string]interface{}
+		assert.Nil(t, testCase.decode(recorder.Body.Bytes(), &actual), "Failed to decode error on test case %d", i)
+		assert.Equal(t, "not_found", actual["code"], "Different error code on test case %d", i)
+	}
+
+	req := NewMockRequest()
+	req.RequestHeader["accept"] = "application/msgpack"
+	req.SendError(ErrNotFound)
+	var actual map[string]interface{}
+	assert.Equal(t, http.StatusNotFound, req.StatusCode, "Different status code of mock request")
+	assert.Equal(t, "application/msgpack", req.ResponseHeader["Content-Type"], "Different content type of mock request")
+	assert.Nil(t, MessagePackCodec{}.Decode(bytes.NewReader(req.Response), &actual), "Failed to decode mock error")
+	assert.Equal(t, "not_found", actual["code"], "Different mock error code")
+}
+
+type textCodec struct{}
+
+func (textCodec) Encode(w io.Writer, v interface{}) error {
+	_, err := w.Write([]byte(strings.ToUpper(v.(codecTestData).Name)))
+	return err
+}
+
+func (textCodec) Decode(r io.Reader, v interface{}) error {
+	data, _ := ioutil.ReadAll(r)
+	v.(*codecTestData).Name = strings.ToLower(string(data))
+	return nil
+}
+
+func TestDeserializeRequestDataCodec(t *testing.T) {
+	App.BeforeTest()
+	App.RegisterCodec("text/plain", textCodec{})
+	defer func() { App.codecs, App.codecOrder = nil, nil }()
+
+	data := codecTestData{Name: "helios", Count: 3}
+	var msgpackBody bytes.Buffer
+	MessagePackCodec{}.Encode(&msgpackBody, data)
+	cborBody, _ := cbor.Marshal(data)
+	type deserializeTestCase struct {
+		contentType   string
+		body          string
+		expected      codecTestData
+		expectedError Error
+	}
+	testCases := []deserializeTestCase{
+		{contentType: "application/json; charset=utf-8", body: `{"name":"helios","count":3}`, expected: data},
+		{contentType: "application/problem+json", body: `{"name":"helios","count":3}`, expected: data},
+		{contentType: "application/xml", body: `<codecTestData><name>helios</name><count>3</count></codecTestData>`, expected: data},
+		{contentType: "application/atom+xml", body: `<codecTestData><name>helios</name><count>3</count></codecTestData>`, expected: data},
+		{contentType: "application/msgpack", body: msgpackBody.String(), expected: data},
+		{contentType: "application/cbor", body: string(cborBody), expected: data},
+		{contentType: "text/plain", body: "HELIOS", expected: codecTestData{Name: "helios"}},
+		{contentType: "application/json", body: `{"name":`, expectedError: ErrJSONParseFailed},
+		{contentType: "application/cbor", body: "not cbor", expectedError: ErrRequestParseFailed},
+		{contentType: "application/pdf", body: "%PDF", expectedError: ErrUnsupportedContentType},
+	}
+	for i, testCase := range testCases {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(testCase.body))
+		request.Header.Set("Content-Type", testCase.contentType)
+		req := NewHTTPRequest(httptest.NewRecorder(), request)
+		var actual codecTestData
+		err := req.DeserializeRequestData(&actual)
+		assert.Equal(t, testCase.expectedError, err, "Different error on test case %d", i)
+		if testCase.expectedError == nil {
+			assert.Equal(t, testCase.expected, actual, "Different request data on test case %d", i)
+		}
+	}
+
+	req := NewMockRequest()
+	req.RequestHeader["content-type"] = "application/msgpack"
+	req.RequestData = msgpackBody.Bytes()
+	var actual codecTestData
+	assert.Nil(t, req.DeserializeRequestData(&actual), "Failed to deserialize mock request")
+	assert.Equal(t, data, actual, "Different mock request data")
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept", "text/plain")
+	Handle(func(req Request) {
+		req.Send(data, http.StatusOK)
+	})(recorder, request)
+	assert.Equal(t, "HELIOS", recorder.Body.String(), "Registered codec should be used to send response")
+}
@@ -0,0 +1,358 @@
+package helios
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Command is a management command that can be run by App.RunCommand
+type Command struct {
+	Name        string
+	Description string
+
+	// SkipInitialize makes the command run without App.Initialize,
+	// so it doesn't need the database and secret key
+	SkipInitialize bool
+
+	// Run executes the command. args doesn't include the command name.
+	Run func(app *Helios, args []string) error
+}
+
+// ErrUnknownCommand returned by RunCommand if there is no command with the name
+var ErrUnknownCommand = errors.New("unknown command")
+
+// RegisterCommand adds command that can be run by RunCommand.
+// Command with the same name as built-in command replaces it.
+func (app *Helios) RegisterCommand(command Command) {
+	if app.commands == nil {
+		app.commands = make(map[string]Command)
+	}
+	app.commands[command.Name] = command
+}
+
+// RunCommand runs the command named by args[1] with the rest of args,
+// so it can be called with os.Args:
+//     if len(os.Args) > 1 {
+//         if err := helios.App.RunCommand(os.Args); err != nil {
+//             log.Fatal(err)
+//         }
+//     }
+// The app is initialized before running the command, unless
+// it is already initialized or the command skips it.
+func (app *Helios) RunCommand(args []string) error {
+	if len(args) < 2 || args[1] == "help" || args[1] == "-h" || args[1] == "--help" {
+		app.printCommands()
+		return nil
+	}
+	command, ok := app.command(args[1])
+	if !ok {
+		app.printCommands()
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, args[1])
+	}
+	if !command.SkipInitialize && DB == nil {
+		if err := app.Initialize(); err != nil {
+			return err
+		}
+		defer app.CloseDB()
+	}
+	return command.Run(app, args[2:])
+}
+
+func (app *Helios) command(name string) (Command, bool) {
+	if command, ok := app.commands[name]; ok {
+		return command, true
+	}
+	for _, command := range builtinCommands() {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+func (app *Helios) printCommands() {
+	commands := make(map[string]Command)
+	for _, command := range builtinCommands() {
+		commands[command.Name] = command
+	}
+	for name, command := range app.commands {
+		commands[name] = command
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(app.output(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Available commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", name, commands[name].Description)
+	}
+	w.Flush()
+}
+
+// SetOutput sets the writer used by commands to print,
+// the default is os.Stdout
+func (app *Helios) SetOutput(w io.Writer) {
+	app.out = w
+}
+
+func (app *Helios) output() io.Writer {
+	if app.out == nil {
+		return os.Stdout
+	}
+	return app.out
+}
+
+func (app *Helios) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(app.output())
+	return flags
+}
+
+func builtinCommands() []Command {
+	return []Command{
+		{Name: "migrate", Description: "apply migrations, or revert them with \"migrate down\", or list them with \"migrate status\"", Run: runMigrate},
+		{Name: "makemigrations", Description: "generate sql migration from the changes of registered models", Run: runMakeMigrations},
+		{Name: "runserver", Description: "serve the app router until interrupted, e.g. \"runserver --addr :8000\"", Run: runServer},
+		{Name: "routes", Description: "list registered routes", SkipInitialize: true, Run: runRoutes},
+		{Name: "createsecret", Description: "print new random secret key", SkipInitialize: true, Run: runCreateSecret},
+		{Name: "dbshell", Description: "open the command-line client of the database", SkipInitialize: true, Run: runDBShell},
+		{Name: "createtoken", Description: "issue api token of a user, e.g. \"createtoken --user alice --scopes read,write --ttl 720h\"", Run: runCreateToken},
+		{Name: "revoketoken", Description: "revoke api token by its id, e.g. \"revoketoken --id 3\"", Run: runRevokeToken},
+	}
+}
+
+// migrationsDir returns the directory of sql migrations,
+// read from HELIOS_MIGRATIONS_DIR with migrations as default
+func migrationsDir() string {
+	if dir := os.Getenv("HELIOS_MIGRATIONS_DIR"); dir != "" {
+		return dir
+	}
+	return "migrations"
+}
+
+func runMigrate(app *Helios, args []string) error {
+	action := "up"
+	if len(args) > 0 && (args[0] == "up" || args[0] == "down" || args[0] == "status") {
+		action = args[0]
+		args = args[1:]
+	}
+	flags := app.newFlagSet("migrate " + action)
+	dir := flags.String("dir", migrationsDir(), "directory of sql migrations")
+	auto := flags.Bool("auto", false, "also auto-migrate the registered models before applying migrations")
+	steps := flags.Int("steps", 1, "number of migrations to revert on migrate down")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*dir); err == nil {
+		if err := app.LoadSQLMigrations(*dir); err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case "status":
+		status, err := app.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(app.output(), 0, 4, 2, ' ', 0)
+		for _, s := range status {
+			if s.Applied {
+				fmt.Fprintf(w, "[X]\t%s\t%s\n", s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(w, "[ ]\t%s\t\n", s.Name)
+			}
+		}
+		return w.Flush()
+	case "down":
+		return app.MigrateDown(*steps)
+	default:
+		if *auto {
+			app.Migrate()
+		}
+		return app.MigrateUp()
+	}
+}
+
+func runMakeMigrations(app *Helios, args []string) error {
+	flags := app.newFlagSet("makemigrations")
+	dir := flags.String("dir", migrationsDir(), "directory to write the sql migration")
+	name := flags.String("name", "auto", "name of the migration")
+	allowDestructive := flags.Bool("allow-destructive", false, "allow changes that lose data, e.g. dropping column")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	files, err := app.GenerateMigration(GenerateMigrationOptions{Dir: *dir, Name: *name, AllowDestructive: *allowDestructive})
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(app.output(), "No changes detected")
+	}
+	for _, file := range files {
+		fmt.Fprintln(app.output(), "Created", file)
+	}
+	return nil
+}
+
+func runServer(app *Helios, args []string) error {
+	flags := app.newFlagSet("runserver")
+	addr := flags.String("addr", ":8000", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	fmt.Fprintln(app.output(), "Listening on", *addr)
+	return app.Run(*addr)
+}
+
+func runRoutes(app *Helios, args []string) error {
+	w := tabwriter.NewWriter(app.output(), 0, 4, 2, ' ', 0)
+	for _, route := range app.Router().Routes() {
+		fmt.Fprintf(w, "%s\t%s\n", route.Method, route.Pattern)
+	}
+	return w.Flush()
+}
+
+func runCreateSecret(app *Helios, args []string) error {
+	key, err := GenerateSecretKey()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(app.output(), key)
+	return nil
+}
+
+func runDBShell(app *Helios, args []string) error {
+	config, err := app.databaseConfig()
+	if err != nil {
+		return err
+	}
+	name, shellArgs, env, err := dbShellCommand(config)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(name, append(shellArgs, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = app.output()
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func runCreateToken(app *Helios, args []string) error {
+	flags := app.newFlagSet("createtoken")
+	username := flags.String("user", "", "username of the token owner")
+	name := flags.String("name", "", "name of the token, e.g. the client using it")
+	scopes := flags.String("scopes", "", "comma separated scopes granted to the token")
+	ttl := flags.Duration("ttl", 0, "lifetime of the token, e.g. 720h, the token never expires if it is zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var user User
+	if err := app.WriteDB().Where("username = ?", *username).First(&user).Error; err != nil {
+		return fmt.Errorf("can't find user %q: %w", *username, err)
+	}
+	plain, token, err := IssueAPIToken(app.WriteDB(), &user, *name, strings.FieldsFunc(*scopes, func(c rune) bool {
+		return c == ',' || c == ' '
+	}), *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(app.output(), "Token %d of %s: %s\n", token.ID, user.Username, plain)
+	return nil
+}
+
+func runRevokeToken(app *Helios, args []string) error {
+	flags := app.newFlagSet("revoketoken")
+	id := flags.Uint("id", 0, "id of the token")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := RevokeAPIToken(app.WriteDB(), *id); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.output(), "Token %d is revoked\n", *id)
+	return nil
+}
+
+// mysqlDSNPattern matches user:password@tcp(host:port)/dbname?params
+var mysqlDSNPattern = regexp.MustCompile(`^(?:([^:@]*)(?::([^@]*))?@)?(?:tcp\(([^:)]*)(?::(\d+))?\))?/([^?]*)`)
+
+// postgresPasswordPattern matches the password of key=value connection string
+var postgresPasswordPattern = regexp.MustCompile(`(^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// dbShellCommand returns the command-line client, its arguments, and the
+// environment variables to connect to the database described by config.
+// The password is passed in environment variable, so it isn't shown in
+// the process list.
+func dbShellCommand(config DatabaseConfig) (string, []string, []string, error) {
+	switch config.Dialect {
+	case "sqlite3":
+		return "sqlite3", []string{config.URL}, nil, nil
+	case "postgres":
+		// psql accepts both url and key=value connection string as dbname
+		dsn, password := postgresDSNWithoutPassword(config.URL)
+		var env []string
+		if password != "" {
+			env = append(env, "PGPASSWORD="+password)
+		}
+		return "psql", []string{dsn}, env, nil
+	case "mysql":
+		match := mysqlDSNPattern.FindStringSubmatch(config.URL)
+		if match == nil {
+			return "", nil, nil, fmt.Errorf("can't parse mysql dsn %q", config.URL)
+		}
+		args := make([]string, 0)
+		var env []string
+		if match[1] != "" {
+			args = append(args, "--user="+match[1])
+		}
+		if match[2] != "" {
+			env = append(env, "MYSQL_PWD="+match[2])
+		}
+		if match[3] != "" {
+			args = append(args, "--host="+match[3])
+		}
+		if match[4] != "" {
+			args = append(args, "--port="+match[4])
+		}
+		return "mysql", append(args, match[5]), env, nil
+	default:
+		return "", nil, nil, fmt.Errorf("dbshell doesn't support %s dialect", config.Dialect)
+	}
+}
+
+// postgresDSNWithoutPassword removes the password from postgres url
+// or key=value connection string, and returns it
+func postgresDSNWithoutPassword(dsn string) (string, string) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil || u.User == nil {
+			return dsn, ""
+		}
+		password, _ := u.User.Password()
+		u.User = url.User(u.User.Username())
+		return u.String(), password
+	}
+	match := postgresPasswordPattern.FindStringSubmatch(dsn)
+	if match == nil {
+		return dsn, ""
+	}
+	password := match[2]
+	if strings.HasPrefix(password, "'") {
+		password = strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(password[1 : len(password)-1])
+	}
+	return strings.TrimSpace(postgresPasswordPattern.ReplaceAllString(dsn, "")), password
+}
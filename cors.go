@@ -0,0 +1,207 @@
+package helios
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig is the config of CORS middleware
+type CORSConfig struct {
+	// AllowedOrigins is the origins that can access the resource, e.g.
+	// https://example.com. "*" allows every origin, and a wildcard
+	// subdomain such as https://*.example.com allows every subdomain.
+	AllowedOrigins []string
+
+	// AllowedMethods is the methods allowed on preflight request,
+	// DefaultCORSAllowedMethods is used if it is empty
+	AllowedMethods []string
+
+	// AllowedHeaders is the request headers allowed on preflight request,
+	// DefaultCORSAllowedHeaders is used if it is empty. "*" allows
+	// every header requested by the client.
+	AllowedHeaders []string
+
+	// ExposedHeaders is the response headers that can be read by the client
+	ExposedHeaders []string
+
+	// AllowCredentials allows cookies and authorization header to be sent.
+	// It can't be used with "*" in AllowedOrigins.
+	AllowCredentials bool
+
+	// MaxAge is how long in seconds the result of preflight request can be cached,
+	// the header is not sent if it is zero
+	MaxAge int
+}
+
+// DefaultCORSAllowedMethods is the allowed methods if CORSConfig.AllowedMethods is empty
+var DefaultCORSAllowedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+}
+
+// DefaultCORSAllowedHeaders is the allowed headers if CORSConfig.AllowedHeaders is empty
+var DefaultCORSAllowedHeaders = []string{
+	"Accept",
+	"Authorization",
+	"Content-Type",
+	"X-Requested-With",
+}
+
+// CreateCORSMiddleware add Access-Control-Allow-Origin headers
+// to the response, see CreateCORSMiddlewareWithConfig.
+func CreateCORSMiddleware(allowedOrigins []string) Middleware {
+	return CreateCORSMiddlewareWithConfig(CORSConfig{AllowedOrigins: allowedOrigins})
+}
+
+// CreateCORSMiddlewareWithConfig returns middleware that adds CORS headers
+// to the response of request from allowed origin. Preflight request, i.e.
+// OPTIONS with Access-Control-Request-Method header, is answered with 204
+// without calling the handler. Request from origin that is not allowed gets
+// no CORS headers, so it is rejected by the browser. The allowed origin is
+// echoed in Access-Control-Allow-Origin, so Vary: Origin is added to every
+// response. It panics if "*" in AllowedOrigins is used with AllowCredentials.
+//     api := helios.App.Group("/api", helios.CreateCORSMiddlewareWithConfig(helios.CORSConfig{
+//         AllowedOrigins:   []string{"https://example.com", "https://*.example.com"},
+//         AllowCredentials: true,
+//         MaxAge:           600,
+//     }))
+func CreateCORSMiddlewareWithConfig(config CORSConfig) Middleware {
+	allAllowed := false
+	for _, allowedOrigin := range config.AllowedOrigins {
+		if allowedOrigin == "*" {
+			allAllowed = true
+		}
+	}
+	if allAllowed && config.AllowCredentials {
+		panic("helios: CORS allowed origin * can't be used with credentials")
+	}
+
+	allowedMethods := config.AllowedMethods
+	if len(allowedMethods) == 0 {
+		allowedMethods = DefaultCORSAllowedMethods
+	}
+	allowedHeaders := config.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = DefaultCORSAllowedHeaders
+	}
+	allHeadersAllowed := false
+	for _, header := range allowedHeaders {
+		if header == "*" {
+			allHeadersAllowed = true
+		}
+	}
+
+	isOriginAllowed := func(origin string) bool {
+		if allAllowed {
+			return true
+		}
+		for _, allowedOrigin := range config.AllowedOrigins {
+			if matchOrigin(allowedOrigin, origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			origin := req.GetHeader("Origin")
+			requestMethod := req.GetHeader("Access-Control-Request-Method")
+			isPreflight := req.GetMethod() == http.MethodOptions && requestMethod != ""
+			// the allowed origin is echoed even for "*", so caches have to key
+			// every response by origin, including the ones without CORS headers
+			req.AddHeader("Vary", "Origin")
+			if origin == "" {
+				f(req)
+				return
+			}
+
+			if !isPreflight {
+				if isOriginAllowed(origin) {
+					req.SetHeader("Access-Control-Allow-Origin", origin)
+					if config.AllowCredentials {
+						req.SetHeader("Access-Control-Allow-Credentials", "true")
+					}
+					if len(config.ExposedHeaders) > 0 {
+						req.SetHeader("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+					}
+				}
+				f(req)
+				return
+			}
+
+			req.AddHeader("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+			requestHeaders := splitHeaderList(req.GetHeader("Access-Control-Request-Headers"))
+			if isOriginAllowed(origin) &&
+				containsFold(allowedMethods, requestMethod) &&
+				(allHeadersAllowed || containsAllFold(allowedHeaders, requestHeaders)) {
+				req.SetHeader("Access-Control-Allow-Origin", origin)
+				req.SetHeader("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+				if len(requestHeaders) > 0 {
+					if allHeadersAllowed {
+						req.SetHeader("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+					} else {
+						req.SetHeader("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+					}
+				}
+				if config.AllowCredentials {
+					req.SetHeader("Access-Control-Allow-Credentials", "true")
+				}
+				if config.MaxAge > 0 {
+					req.SetHeader("Access-Control-Max-Age", strconv.Itoa(config.MaxAge))
+				}
+			}
+			req.SendStatus(http.StatusNoContent)
+		}
+	}
+}
+
+// matchOrigin returns true if origin is the allowed origin, or a subdomain
+// of it if the allowed origin has wildcard, e.g. https://*.example.com
+func matchOrigin(allowedOrigin string, origin string) bool {
+	allowedOrigin = strings.ToLower(allowedOrigin)
+	origin = strings.ToLower(origin)
+	i := strings.Index(allowedOrigin, "*")
+	if i < 0 {
+		return allowedOrigin == origin
+	}
+	prefix, suffix := allowedOrigin[:i], allowedOrigin[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	subdomain := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(subdomain, "/:") && !strings.HasPrefix(subdomain, ".") && !strings.HasSuffix(subdomain, ".")
+}
+
+func splitHeaderList(header string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(header, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAllFold(values []string, wanted []string) bool {
+	for _, value := range wanted {
+		if !containsFold(values, value) {
+			return false
+		}
+	}
+	return true
+}
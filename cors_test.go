@@ -0,0 +1,211 @@
+package helios
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCORSMiddlewareWithConfig(t *testing.T) {
+	App.BeforeTest()
+
+	cors := CreateCORSMiddlewareWithConfig(CORSConfig{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-Token"},
+		ExposedHeaders:   []string{"X-Total-Count"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	handlerCalled := false
+	handler := cors(func(req Request) {
+		handlerCalled = true
+		req.SendJSON("ok", http.StatusOK)
+	})
+
+	type corsTestCase struct {
+		method                string
+		origin                string
+		requestMethod         string
+		requestHeaders        string
+		expectedCode          int
+		expectedHandlerCalled bool
+		expectedHeader        map[string]string
+	}
+	testCases := []corsTestCase{{
+		method:                "GET",
+		expectedCode:          http.StatusOK,
+		expectedHandlerCalled: true,
+		expectedHeader:        map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Accept, Origin"},
+	}, {
+		method:                "GET",
+		origin:                "https://example.com",
+		expectedCode:          http.StatusOK,
+		expectedHandlerCalled: true,
+		expectedHeader: map[string]string{
+			"Access-Control-Allow-Origin":      "https://example.com",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Expose-Headers":    "X-Total-Count",
+			"Access-Control-Allow-Methods":     "",
+			"Vary":                             "Accept, Origin",
+		},
+	}, {
+		method:                "GET",
+		origin:                "https://api.example.org",
+		expectedCode:          http.StatusOK,
+		expectedHandlerCalled: true,
+		expectedHeader:        map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"},
+	}, {
+		method:                "GET",
+		origin:                "https://example.org",
+		expectedCode:          http.StatusOK,
+		expectedHandlerCalled: true,
+		expectedHeader:        map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Accept, Origin"},
+	}, {
+		method:                "GET",
+		origin:                "https://evil.com",
+		expectedCode:          http.StatusOK,
+		expectedHandlerCalled: true,
+		expectedHeader:        map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Credentials": ""},
+	}, {
+		method:         "OPTIONS",
+		origin:         "https://example.com",
+		requestMethod:  "POST",
+		requestHeaders: "content-type, x-token",
+		expectedCode:   http.StatusNoContent,
+		expectedHeader: map[string]string{
+			"Access-Control-Allow-Origin":      "https://example.com",
+			"Access-Control-Allow-Methods":     "GET, POST",
+			"Access-Control-Allow-Headers":     "Content-Type, X-Token",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Max-Age":           "600",
+			"Access-Control-Expose-Headers":    "",
+			"Vary":                             "Accept, Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		},
+	}, {
+		method:         "OPTIONS",
+		origin:         "https://example.com",
+		requestMethod:  "DELETE",
+		expectedCode:   http.StatusNoContent,
+		expectedHeader: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+	}, {
+		method:         "OPTIONS",
+		origin:         "https://example.com",
+		requestMethod:  "POST",
+		requestHeaders: "X-Other",
+		expectedCode:   http.StatusNoContent,
+		expectedHeader: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Headers": ""},
+	}, {
+		method:         "OPTIONS",
+		origin:         "https://evil.com",
+		requestMethod:  "GET",
+		expectedCode:   http.StatusNoContent,
+		expectedHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+	}, {
+		method:                "OPTIONS",
+		origin:                "https://example.com",
+		expectedCode:          http.StatusOK,
+		expectedHandlerCalled: true,
+		expectedHeader:        map[string]string{"Access-Control-Allow-Origin": "https://example.com"},
+	}}
+	for i, testCase := range testCases {
+		handlerCalled = false
+		req := NewMockRequest()
+		req.Method = testCase.method
+		req.ResponseHeader["Vary"] = "Accept"
+		if testCase.origin != "" {
+			req.RequestHeader["origin"] = testCase.origin
+		}
+		if testCase.requestMethod != "" {
+			req.RequestHeader["access-control-request-method"] = testCase.requestMethod
+		}
+		if testCase.requestHeaders != "" {
+			req.RequestHeader["access-control-request-headers"] = testCase.requestHeaders
+		}
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+		assert.Equal(t, testCase.expectedHandlerCalled, handlerCalled, "Unexpected handler call on test case %d", i)
+		for key, value := range testCase.expectedHeader {
+			assert.Equal(t, value, req.ResponseHeader[key], "Unexpected %s header on test case %d", key, i)
+		}
+	}
+}
+
+func TestCreateCORSMiddlewareWithConfigAllowAllHeaders(t *testing.T) {
+	App.BeforeTest()
+
+	cors := CreateCORSMiddlewareWithConfig(CORSConfig{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}})
+	req := NewMockRequest()
+	req.Method = "OPTIONS"
+	req.RequestHeader["origin"] = "http://localhost:3000"
+	req.RequestHeader["access-control-request-method"] = "PATCH"
+	req.RequestHeader["access-control-request-headers"] = "X-Anything"
+	cors(func(req Request) {})(&req)
+
+	assert.Equal(t, http.StatusNoContent, req.StatusCode, "Preflight should be answered with 204")
+	assert.Equal(t, "http://localhost:3000", req.ResponseHeader["Access-Control-Allow-Origin"], "Every origin should be allowed")
+	assert.Equal(t, "Origin, Access-Control-Request-Method, Access-Control-Request-Headers", req.ResponseHeader["Vary"], "Echoed origin should vary by origin")
+	assert.Equal(t, "X-Anything", req.ResponseHeader["Access-Control-Allow-Headers"], "Requested headers should be reflected")
+	assert.Equal(t, "", req.ResponseHeader["Access-Control-Allow-Credentials"], "Credentials should not be allowed by default")
+}
+
+func TestCreateCORSMiddlewareWithConfigWildcardCredentials(t *testing.T) {
+	assert.Panics(t, func() {
+		CreateCORSMiddlewareWithConfig(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	}, "Wildcard origin with credentials should be rejected")
+}
+
+func TestMatchOrigin(t *testing.T) {
+	type matchOriginTestCase struct {
+		allowedOrigin string
+		origin        string
+		expected      bool
+	}
+	testCases := []matchOriginTestCase{
+		{allowedOrigin: "https://example.com", origin: "https://example.com", expected: true},
+		{allowedOrigin: "https://example.com", origin: "HTTPS://EXAMPLE.COM", expected: true},
+		{allowedOrigin: "https://example.com", origin: "http://example.com", expected: false},
+		{allowedOrigin: "https://*.example.com", origin: "https://api.example.com", expected: true},
+		{allowedOrigin: "https://*.example.com", origin: "https://v1.api.example.com", expected: true},
+		{allowedOrigin: "https://*.example.com", origin: "https://example.com", expected: false},
+		{allowedOrigin: "https://*.example.com", origin: "https://.example.com", expected: false},
+		{allowedOrigin: "https://*.example.com", origin: "https://evil.com/.example.com", expected: false},
+		{allowedOrigin: "https://*.example.com", origin: "https://evilexample.com", expected: false},
+		{allowedOrigin: "https://*.example.com", origin: "https://api.example.com:8080", expected: false},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, matchOrigin(testCase.allowedOrigin, testCase.origin), "Unexpected match of %s on %s", testCase.origin, testCase.allowedOrigin)
+	}
+}
+
+func TestRouterPreflight(t *testing.T) {
+	App.BeforeTest()
+
+	router := NewRouter()
+	api := router.Group("/api", CreateCORSMiddlewareWithConfig(CORSConfig{AllowedOrigins: []string{"https://example.com"}}))
+	api.POST("/users/{id}", func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+
+	request := httptest.NewRequest("OPTIONS", "/api/users/1", nil)
+	request.Header.Set("Origin", "https://example.com")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNoContent, recorder.Code, "Preflight should be answered by route middleware")
+	assert.Equal(t, "https://example.com", recorder.Header().Get("Access-Control-Allow-Origin"), "Preflight should be allowed")
+	assert.Equal(t, "POST, OPTIONS", recorder.Header().Get("Allow"), "Allow header should contain the route methods")
+
+	request = httptest.NewRequest("POST", "/api/users/1", nil)
+	recorder = httptest.NewRecorder()
+	recorder.Header().Set("Vary", "Accept-Encoding")
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, []string{"Accept-Encoding", "Origin"}, recorder.Header()["Vary"], "Vary should be appended on request without origin")
+
+	request = httptest.NewRequest("OPTIONS", "/api/posts", nil)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "OPTIONS to unknown path should be not found")
+}
@@ -0,0 +1,173 @@
+package helios
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRFConfig is the config of CSRF middleware
+type CSRFConfig struct {
+	// HeaderName is the request header carrying the token, and the response
+	// header the token is sent with. The default is X-CSRF-Token.
+	HeaderName string
+
+	// FormField is the form field carrying the token, used if the header
+	// is empty. The default is csrf_token.
+	FormField string
+
+	// AllowedHosts is the hosts that can send unsafe request, checked against
+	// Origin header, or Referer header if there is no Origin. A host can be a
+	// wildcard subdomain, e.g. *.example.com. If it is empty, only the host of
+	// the request is allowed.
+	AllowedHosts []string
+
+	// ExemptPaths is the route patterns that are not checked,
+	// e.g. /api/webhooks/{provider}
+	ExemptPaths []string
+
+	// ExemptBearerToken skips the check for request authenticated by
+	// TokenAuthBackend, so API authenticated by token can share the routes
+	// with session authenticated pages. The owner of the token becomes the
+	// user of request, so the session can't be used without the check.
+	ExemptBearerToken bool
+}
+
+// ErrCSRFFailed returned when unsafe request doesn't have valid
+// CSRF token or comes from host that is not allowed
+var ErrCSRFFailed = ErrorAPI{
+	StatusCode: http.StatusForbidden,
+	Code:       "csrf_failed",
+	Message:    "CSRF verification failed",
+}
+
+const csrfSessionKey = "_csrf_token"
+
+// CSRFMiddleware is CSRF middleware with the default config
+var CSRFMiddleware = CreateCSRFMiddleware(CSRFConfig{})
+
+// CSRFToken returns the CSRF token of the session of request, generating
+// and saving new one if the session doesn't have it yet. The token can be
+// embedded in form as hidden field.
+func CSRFToken(req Request) string {
+	if token, ok := req.GetSessionData(csrfSessionKey).(string); ok && token != "" {
+		return token
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	req.SetSessionData(csrfSessionKey, token)
+	if err := req.SaveSession(); err != nil {
+		App.Logger().Printf("csrf: failed to save session: %v", err)
+	}
+	return token
+}
+
+// CreateCSRFMiddleware returns middleware that protects unsafe requests,
+// i.e. other than GET, HEAD, OPTIONS, and TRACE, against cross-site request
+// forgery. The request has to carry the token of its session, returned by
+// CSRFToken, in the header or the form field, and its Origin or Referer
+// must be an allowed host. Otherwise ErrCSRFFailed is sent. Safe requests
+// get the token in the response header.
+//     helios.App.Group("/", helios.CreateCSRFMiddleware(helios.CSRFConfig{
+//         AllowedHosts:      []string{"example.com", "*.example.com"},
+//         ExemptPaths:       []string{"/webhooks/{provider}"},
+//         ExemptBearerToken: true,
+//     }))
+func CreateCSRFMiddleware(config CSRFConfig) Middleware {
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.FormField == "" {
+		config.FormField = "csrf_token"
+	}
+	exemptRoutes := make([]*route, 0, len(config.ExemptPaths))
+	for _, pattern := range config.ExemptPaths {
+		exemptRoutes = append(exemptRoutes, &route{pattern: pattern, segments: App.Router().parsePattern(pattern)})
+	}
+
+	isExempt := func(req Request) bool {
+		if config.ExemptBearerToken && isTokenAuthenticated(req) {
+			return true
+		}
+		pathSegments := splitPath(req.GetPath())
+		for _, r := range exemptRoutes {
+			if _, ok := r.matchPath(pathSegments); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	isHostAllowed := func(req Request) bool {
+		source := req.GetHeader("Origin")
+		if source == "" || source == "null" {
+			source = req.GetHeader("Referer")
+		}
+		if source == "" {
+			// non-browser client, the token is still required
+			return true
+		}
+		sourceURL, err := url.Parse(source)
+		if err != nil || sourceURL.Host == "" {
+			return false
+		}
+		if len(config.AllowedHosts) == 0 {
+			return strings.EqualFold(sourceURL.Host, req.GetHeader("Host"))
+		}
+		for _, host := range config.AllowedHosts {
+			if matchOrigin(host, sourceURL.Host) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			switch req.GetMethod() {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				req.SetHeader(config.HeaderName, CSRFToken(req))
+				f(req)
+				return
+			}
+			if isExempt(req) {
+				f(req)
+				return
+			}
+
+			expected, _ := req.GetSessionData(csrfSessionKey).(string)
+			actual := req.GetHeader(config.HeaderName)
+			if actual == "" {
+				actual = req.GetFormValue(config.FormField)
+			}
+			if !isHostAllowed(req) || expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+				req.SendError(ErrCSRFFailed)
+				return
+			}
+			f(req)
+		}
+	}
+}
+
+// isTokenAuthenticated returns true if the request is authenticated by
+// TokenAuthBackend, and loads the owner of the token as the user of request
+func isTokenAuthenticated(req Request) bool {
+	if CurrentAPIToken(req) != nil {
+		return true
+	}
+	if bearerToken(req) == "" {
+		return false
+	}
+	user, err := TokenAuthBackend{}.Authenticate(req)
+	if err != nil || user == nil {
+		return false
+	}
+	req.SetContextData(userContextKey, user)
+	return true
+}
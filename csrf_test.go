@@ -0,0 +1,158 @@
+package helios
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCSRFMiddleware(t *testing.T) {
+	App.BeforeTest()
+
+	csrf := CreateCSRFMiddleware(CSRFConfig{
+		AllowedHosts:      []string{"example.com", "*.example.com"},
+		ExemptPaths:       []string{"/webhooks/{provider}"},
+		ExemptBearerToken: true,
+	})
+	handler := csrf(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+	token := "session-token"
+
+	type csrfTestCase struct {
+		method       string
+		path         string
+		sessionToken string
+		header       map[string]string
+		form         url.Values
+		expectedCode int
+	}
+	testCases := []csrfTestCase{
+		{method: "GET", path: "/orders", expectedCode: http.StatusOK},
+		{method: "POST", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": token}, expectedCode: http.StatusOK},
+		{method: "POST", path: "/orders", sessionToken: token, form: url.Values{"csrf_token": {token}}, expectedCode: http.StatusOK},
+		{method: "DELETE", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": token, "origin": "https://example.com"}, expectedCode: http.StatusOK},
+		{method: "PUT", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": token, "referer": "https://shop.example.com/cart"}, expectedCode: http.StatusOK},
+		{method: "POST", path: "/orders", sessionToken: token, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/orders", header: map[string]string{"x-csrf-token": token}, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": "wrong"}, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/orders", sessionToken: token, form: url.Values{"csrf_token": {"wrong"}}, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": token, "origin": "https://evil.com"}, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": token, "referer": "https://example.com.evil.com/"}, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/orders", sessionToken: token, header: map[string]string{"x-csrf-token": token, "origin": "null", "referer": "not a url"}, expectedCode: http.StatusForbidden},
+		{method: "POST", path: "/webhooks/stripe", expectedCode: http.StatusOK},
+		{method: "POST", path: "/webhooks", expectedCode: http.StatusForbidden},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		req.Method = testCase.method
+		req.Path = testCase.path
+		if testCase.sessionToken != "" {
+			req.SessionData[csrfSessionKey] = testCase.sessionToken
+		}
+		for key, value := range testCase.header {
+			req.RequestHeader[key] = value
+		}
+		if testCase.form != nil {
+			req.RequestData = testCase.form
+		}
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+		if testCase.expectedCode == http.StatusForbidden {
+			assert.Equal(t, `{"code":"csrf_failed","message":"CSRF verification failed"}`, string(req.JSONResponse), "Unexpected response on test case %d", i)
+		}
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	App.BeforeTest()
+
+	req := NewMockRequest()
+	CSRFMiddleware(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})(&req)
+	token := req.ResponseHeader["X-CSRF-Token"]
+	assert.NotEmpty(t, token, "Token should be sent on safe request")
+	assert.Equal(t, token, req.SessionData[csrfSessionKey], "Token should be stored in session")
+	assert.Equal(t, token, CSRFToken(&req), "Token should be kept for the session")
+}
+
+func TestCSRFMiddlewareWithSession(t *testing.T) {
+	App.BeforeTest()
+
+	router := NewRouter()
+	group := router.Group("/", CSRFMiddleware)
+	group.GET("/form", func(req Request) {
+		req.SendJSON(CSRFToken(req), http.StatusOK)
+	})
+	group.POST("/form", func(req Request) {
+		var data struct {
+			Name string `form:"name"`
+		}
+		if err := req.DeserializeRequestData(&data); err != nil {
+			req.SendError(err)
+			return
+		}
+		req.SendJSON(data.Name, http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "http://example.com/form", nil))
+	token := recorder.Header().Get("X-CSRF-Token")
+	assert.Equal(t, `"`+token+`"`, recorder.Body.String(), "Token should be available to the handler")
+	cookies := recorder.Result().Cookies()
+
+	post := func(form url.Values, origin string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", "http://example.com/form", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Origin", origin)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	recorder = post(url.Values{"name": {"alice"}, "csrf_token": {token}}, "http://example.com")
+	assert.Equal(t, http.StatusOK, recorder.Code, "Form with token should be accepted")
+	assert.Equal(t, `"alice"`, recorder.Body.String(), "Form should still be readable by the handler")
+
+	recorder = post(url.Values{"name": {"alice"}, "csrf_token": {token}}, "http://evil.com")
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "Form from other host should be rejected")
+	recorder = post(url.Values{"name": {"alice"}}, "http://example.com")
+	assert.Equal(t, http.StatusForbidden, recorder.Code, "Form without token should be rejected")
+}
+
+func TestCSRFMiddlewareBearerToken(t *testing.T) {
+	user, teardown := setupTokenTest(t)
+	defer teardown()
+
+	plain, _, err := IssueAPIToken(DB, user, "mobile", nil, time.Hour)
+	assert.Nil(t, err, "Failed to issue token")
+	handler := CreateCSRFMiddleware(CSRFConfig{ExemptBearerToken: true})(func(req Request) {
+		req.SendJSON(CurrentUser(req).Username, http.StatusOK)
+	})
+
+	type bearerTokenTestCase struct {
+		authorization string
+		expectedCode  int
+	}
+	testCases := []bearerTokenTestCase{
+		{authorization: "Bearer " + plain, expectedCode: http.StatusOK},
+		{authorization: "Bearer abc", expectedCode: http.StatusForbidden},
+		{authorization: "Basic abc", expectedCode: http.StatusForbidden},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		req.Method = "POST"
+		req.RequestHeader["authorization"] = testCase.authorization
+		req.SessionData[sessionUserIDKey] = user.ID
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+	}
+}
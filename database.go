@@ -0,0 +1,260 @@
+package helios
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// DatabaseConfig is the configuration used to open a database connection.
+// Dialect other than sqlite3 has to be imported by the user, for example:
+//     import _ "github.com/jinzhu/gorm/dialects/postgres"
+type DatabaseConfig struct {
+	// Dialect is the gorm dialect name, e.g. sqlite3, postgres, mysql, mssql
+	Dialect string
+
+	// URL is the data source name passed to the driver
+	URL string
+
+	// MaxIdleConns is the maximum number of idle connections in the pool.
+	// Zero means using the database/sql default.
+	MaxIdleConns int
+
+	// MaxOpenConns is the maximum number of open connections to the database.
+	// Zero means unlimited.
+	MaxOpenConns int
+
+	// ConnMaxLifetime is the maximum amount of time a connection may be reused.
+	// Zero means connections are reused forever.
+	ConnMaxLifetime time.Duration
+}
+
+const (
+	defaultDatabaseDialect = "sqlite3"
+	defaultDatabaseURL     = "db.sqlite3"
+)
+
+// ErrDatabaseURLEmpty returned by Initialize if the dialect
+// is not sqlite3 but the url of the database is not set
+var ErrDatabaseURLEmpty = errors.New("database url is empty, set HELIOS_DB_URL or call SetDatabaseConfig")
+
+// DatabaseConfigFromEnv returns database configuration read from
+// the environment variables:
+//     HELIOS_DB_DIALECT, HELIOS_DB_URL, HELIOS_DB_MAX_IDLE_CONNS,
+//     HELIOS_DB_MAX_OPEN_CONNS, HELIOS_DB_CONN_MAX_LIFETIME
+// Unset variables are left as zero value. HELIOS_DB_CONN_MAX_LIFETIME
+// uses time.ParseDuration format, e.g. "5m".
+func DatabaseConfigFromEnv() (DatabaseConfig, error) {
+	var config DatabaseConfig
+	var err error
+
+	config.Dialect = os.Getenv("HELIOS_DB_DIALECT")
+	config.URL = os.Getenv("HELIOS_DB_URL")
+	if config.MaxIdleConns, err = intFromEnv("HELIOS_DB_MAX_IDLE_CONNS"); err != nil {
+		return config, err
+	}
+	if config.MaxOpenConns, err = intFromEnv("HELIOS_DB_MAX_OPEN_CONNS"); err != nil {
+		return config, err
+	}
+	if lifetime := os.Getenv("HELIOS_DB_CONN_MAX_LIFETIME"); lifetime != "" {
+		config.ConnMaxLifetime, err = time.ParseDuration(lifetime)
+		if err != nil {
+			return config, fmt.Errorf("HELIOS_DB_CONN_MAX_LIFETIME: %v", err)
+		}
+	}
+	return config, nil
+}
+
+// merge fills the empty fields of config with the fields of other
+func (config DatabaseConfig) merge(other DatabaseConfig) DatabaseConfig {
+	if config.Dialect == "" {
+		config.Dialect = other.Dialect
+	}
+	if config.URL == "" {
+		config.URL = other.URL
+	}
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = other.MaxIdleConns
+	}
+	if config.MaxOpenConns == 0 {
+		config.MaxOpenConns = other.MaxOpenConns
+	}
+	if config.ConnMaxLifetime == 0 {
+		config.ConnMaxLifetime = other.ConnMaxLifetime
+	}
+	return config
+}
+
+// OpenDatabase opens new database connection using the config
+// and applies the connection pool settings
+func OpenDatabase(config DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(config.Dialect, config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxIdleConns != 0 {
+		db.DB().SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.MaxOpenConns != 0 {
+		db.DB().SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.ConnMaxLifetime != 0 {
+		db.DB().SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	return db, nil
+}
+
+func intFromEnv(key string) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s should be integer, got %q", key, value)
+	}
+	return result, nil
+}
+
+// DefaultDatabase is the name of the database opened by Initialize.
+// It always refers to the global DB.
+const DefaultDatabase = "default"
+
+// DatabaseRouter decides which registered database is used
+// for reading and which one is used for writing. Databases returns
+// the names of all databases the router may choose, so they can
+// be checked by SetDatabaseRouter.
+type DatabaseRouter interface {
+	DBForRead() string
+	DBForWrite() string
+	Databases() []string
+}
+
+// PrimaryReplicaRouter sends every write to the Primary database
+// and spreads the reads across Replicas in round-robin manner.
+// If there is no replica, reads are sent to the Primary.
+type PrimaryReplicaRouter struct {
+	Primary  string
+	Replicas []string
+	next     uint32
+}
+
+// DBForRead returns the next replica name
+func (router *PrimaryReplicaRouter) DBForRead() string {
+	if len(router.Replicas) == 0 {
+		return router.DBForWrite()
+	}
+	n := atomic.AddUint32(&router.next, 1)
+	return router.Replicas[(n-1)%uint32(len(router.Replicas))]
+}
+
+// DBForWrite returns the primary name, or DefaultDatabase if it is empty
+func (router *PrimaryReplicaRouter) DBForWrite() string {
+	if router.Primary == "" {
+		return DefaultDatabase
+	}
+	return router.Primary
+}
+
+// Databases returns the primary and the replicas names
+func (router *PrimaryReplicaRouter) Databases() []string {
+	return append([]string{router.DBForWrite()}, router.Replicas...)
+}
+
+// RegisterDatabase registers an opened database connection with a name,
+// so it can be retrieved by Database or by the Request. Registering
+// DefaultDatabase replaces the global DB.
+func (app *Helios) RegisterDatabase(name string, db *gorm.DB) {
+	if name == DefaultDatabase {
+		DB = db
+		return
+	}
+	app.dbMutex.Lock()
+	defer app.dbMutex.Unlock()
+	if app.databases == nil {
+		app.databases = make(map[string]*gorm.DB)
+	}
+	app.databases[name] = db
+}
+
+// ConnectDatabase opens the database using the config
+// and registers it with the name
+func (app *Helios) ConnectDatabase(name string, config DatabaseConfig) error {
+	db, err := OpenDatabase(config)
+	if err != nil {
+		return err
+	}
+	app.RegisterDatabase(name, db)
+	return nil
+}
+
+// Database returns the registered database with the name,
+// or nil if there is no database registered with that name
+func (app *Helios) Database(name string) *gorm.DB {
+	if name == DefaultDatabase {
+		return DB
+	}
+	app.dbMutex.RLock()
+	defer app.dbMutex.RUnlock()
+	return app.databases[name]
+}
+
+// SetDatabaseRouter sets the router used by ReadDB and WriteDB. The
+// databases of the router have to be registered before, otherwise error
+// is returned and the router is not set. Nil router removes the router.
+func (app *Helios) SetDatabaseRouter(router DatabaseRouter) error {
+	if router != nil {
+		for _, name := range router.Databases() {
+			if name != DefaultDatabase && app.Database(name) == nil {
+				return fmt.Errorf("database %q of router is not registered", name)
+			}
+		}
+	}
+	app.dbRouter = router
+	return nil
+}
+
+// ReadDB returns the database that should be used for reading.
+// Without router, it returns the default database. It panics if
+// the router returns the name of unregistered database.
+func (app *Helios) ReadDB() *gorm.DB {
+	if app.dbRouter == nil {
+		return DB
+	}
+	return app.routedDatabase(app.dbRouter.DBForRead())
+}
+
+// WriteDB returns the database that should be used for writing.
+// Without router, it returns the default database. It panics if
+// the router returns the name of unregistered database.
+func (app *Helios) WriteDB() *gorm.DB {
+	if app.dbRouter == nil {
+		return DB
+	}
+	return app.routedDatabase(app.dbRouter.DBForWrite())
+}
+
+// routedDatabase returns the database with the name returned by the router,
+// so a misconfigured router fails here instead of nil database used later
+func (app *Helios) routedDatabase(name string) *gorm.DB {
+	db := app.Database(name)
+	if db == nil && name != DefaultDatabase {
+		panic("helios: database router returns unregistered database " + name)
+	}
+	return db
+}
+
+// closeDatabases closes all registered database except the default one
+func (app *Helios) closeDatabases() {
+	app.dbMutex.Lock()
+	defer app.dbMutex.Unlock()
+	for name, db := range app.databases {
+		db.Close()
+		delete(app.databases, name)
+	}
+}
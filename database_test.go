@@ -0,0 +1,129 @@
+package helios
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseConfigFromEnv(t *testing.T) {
+	os.Setenv("HELIOS_DB_DIALECT", "postgres")
+	os.Setenv("HELIOS_DB_URL", "host=localhost user=helios")
+	os.Setenv("HELIOS_DB_MAX_IDLE_CONNS", "2")
+	os.Setenv("HELIOS_DB_MAX_OPEN_CONNS", "10")
+	os.Setenv("HELIOS_DB_CONN_MAX_LIFETIME", "5m")
+	defer os.Unsetenv("HELIOS_DB_DIALECT")
+	defer os.Unsetenv("HELIOS_DB_URL")
+	defer os.Unsetenv("HELIOS_DB_MAX_IDLE_CONNS")
+	defer os.Unsetenv("HELIOS_DB_MAX_OPEN_CONNS")
+	defer os.Unsetenv("HELIOS_DB_CONN_MAX_LIFETIME")
+
+	config, err := DatabaseConfigFromEnv()
+	assert.Nil(t, err, "Failed to read database config from env")
+	assert.Equal(t, "postgres", config.Dialect, "Different dialect")
+	assert.Equal(t, "host=localhost user=helios", config.URL, "Different url")
+	assert.Equal(t, 2, config.MaxIdleConns, "Different max idle conns")
+	assert.Equal(t, 10, config.MaxOpenConns, "Different max open conns")
+	assert.Equal(t, 5*time.Minute, config.ConnMaxLifetime, "Different conn max lifetime")
+
+	os.Setenv("HELIOS_DB_MAX_OPEN_CONNS", "ten")
+	_, err = DatabaseConfigFromEnv()
+	assert.NotNil(t, err, "Non integer max open conns should return error")
+
+	os.Setenv("HELIOS_DB_MAX_OPEN_CONNS", "10")
+	os.Setenv("HELIOS_DB_CONN_MAX_LIFETIME", "forever")
+	_, err = DatabaseConfigFromEnv()
+	assert.NotNil(t, err, "Bad duration should return error")
+}
+
+func TestDatabaseConfigMerge(t *testing.T) {
+	config := DatabaseConfig{Dialect: "mysql", MaxOpenConns: 5}
+	merged := config.merge(DatabaseConfig{Dialect: "postgres", URL: "dsn", MaxOpenConns: 10, MaxIdleConns: 3})
+
+	assert.Equal(t, "mysql", merged.Dialect, "Set field should not be overwritten")
+	assert.Equal(t, "dsn", merged.URL, "Empty field should be filled")
+	assert.Equal(t, 5, merged.MaxOpenConns, "Set field should not be overwritten")
+	assert.Equal(t, 3, merged.MaxIdleConns, "Empty field should be filled")
+}
+
+func TestAppDatabaseConfig(t *testing.T) {
+	defer App.SetDatabaseConfig(DatabaseConfig{})
+	defer os.Unsetenv("HELIOS_DB_DIALECT")
+
+	type databaseConfigTestCase struct {
+		envDialect    string
+		config        DatabaseConfig
+		expected      DatabaseConfig
+		expectedError error
+	}
+	testCases := []databaseConfigTestCase{
+		{expected: DatabaseConfig{Dialect: "sqlite3", URL: "db.sqlite3"}},
+		{config: DatabaseConfig{Dialect: "sqlite3"}, expected: DatabaseConfig{Dialect: "sqlite3", URL: "db.sqlite3"}},
+		{config: DatabaseConfig{URL: "test.sqlite3"}, expected: DatabaseConfig{Dialect: "sqlite3", URL: "test.sqlite3"}},
+		{config: DatabaseConfig{Dialect: "postgres", URL: "host=localhost"}, expected: DatabaseConfig{Dialect: "postgres", URL: "host=localhost"}},
+		{config: DatabaseConfig{Dialect: "postgres"}, expectedError: ErrDatabaseURLEmpty},
+		{envDialect: "mysql", expectedError: ErrDatabaseURLEmpty},
+	}
+	for i, testCase := range testCases {
+		os.Setenv("HELIOS_DB_DIALECT", testCase.envDialect)
+		App.SetDatabaseConfig(testCase.config)
+		config, err := App.databaseConfig()
+		assert.Equal(t, testCase.expectedError, err, "Different error on test case %d", i)
+		if testCase.expectedError == nil {
+			assert.Equal(t, testCase.expected, config, "Different config on test case %d", i)
+		}
+	}
+}
+
+func TestOpenDatabase(t *testing.T) {
+	db, err := OpenDatabase(DatabaseConfig{Dialect: "sqlite3", URL: ":memory:", MaxOpenConns: 1})
+	assert.Nil(t, err, "Failed to open sqlite database")
+	assert.Equal(t, 1, db.DB().Stats().MaxOpenConnections, "Max open conns should be applied")
+	db.Close()
+
+	_, err = OpenDatabase(DatabaseConfig{Dialect: "unknown", URL: "abc"})
+	assert.NotNil(t, err, "Unknown dialect should return error")
+}
+
+func TestDatabaseRegistry(t *testing.T) {
+	App.BeforeTest()
+	defer App.SetDatabaseRouter(nil)
+
+	replica1, _ := OpenDatabase(DatabaseConfig{Dialect: "sqlite3", URL: ":memory:"})
+	replica2, _ := OpenDatabase(DatabaseConfig{Dialect: "sqlite3", URL: ":memory:"})
+	App.RegisterDatabase("replica1", replica1)
+	App.RegisterDatabase("replica2", replica2)
+	errConnect := App.ConnectDatabase("analytics", DatabaseConfig{Dialect: "sqlite3", URL: ":memory:"})
+	defer App.closeDatabases()
+
+	assert.Nil(t, errConnect, "Failed to connect database")
+	assert.Equal(t, DB, App.Database(DefaultDatabase), "Default database should be the global DB")
+	assert.Equal(t, replica1, App.Database("replica1"), "Different registered database")
+	assert.NotNil(t, App.Database("analytics"), "Connected database should be registered")
+	assert.Nil(t, App.Database("unknown"), "Unregistered database should be nil")
+	assert.Equal(t, DB, App.ReadDB(), "Without router, read database is the default one")
+	assert.Equal(t, DB, App.WriteDB(), "Without router, write database is the default one")
+
+	assert.Nil(t, App.SetDatabaseRouter(&PrimaryReplicaRouter{Replicas: []string{"replica1", "replica2"}}), "Failed to set router")
+	assert.Equal(t, replica1, App.ReadDB(), "Reads should be sent to first replica")
+	assert.Equal(t, replica2, App.ReadDB(), "Reads should be sent to next replica")
+	assert.Equal(t, replica1, App.ReadDB(), "Reads should be sent in round-robin")
+	assert.Equal(t, DB, App.WriteDB(), "Writes should be sent to primary")
+
+	req := NewMockRequest()
+	assert.Equal(t, replica2, req.GetDB("replica2"), "Request should retrieve database by name")
+	assert.Equal(t, replica2, req.GetReadDB(), "Request should use the router for reads")
+	assert.Equal(t, DB, req.GetWriteDB(), "Request should use the router for writes")
+
+	assert.Nil(t, App.SetDatabaseRouter(&PrimaryReplicaRouter{Primary: "analytics"}), "Failed to set router")
+	assert.Equal(t, App.Database("analytics"), App.ReadDB(), "Without replica, reads should be sent to primary")
+
+	router := &PrimaryReplicaRouter{Primary: "unknown", Replicas: []string{"replica3"}}
+	assert.NotNil(t, App.SetDatabaseRouter(router), "Router with unregistered database should be rejected")
+	assert.Equal(t, App.Database("analytics"), App.WriteDB(), "Rejected router should not be set")
+	App.dbRouter = router
+	assert.PanicsWithValue(t, "helios: database router returns unregistered database replica3", func() { App.ReadDB() }, "Unregistered replica should panic")
+	assert.PanicsWithValue(t, "helios: database router returns unregistered database unknown", func() { App.WriteDB() }, "Unregistered primary should panic")
+}
@@ -1,6 +1,11 @@
 package helios
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
 
 // Error is interface of error that can be thrown
 // for response
@@ -30,6 +35,11 @@ func (apiError ErrorAPI) GetStatusCode() int {
 	return apiError.StatusCode
 }
 
+// Error returns the message, so ErrorAPI can be used as error
+func (apiError ErrorAPI) Error() string {
+	return apiError.Message
+}
+
 // ErrorForm is common error, usually after parsing the request body
 type ErrorForm struct {
 	Code          string
@@ -164,6 +174,41 @@ func (formError ErrorForm) GetStatusCode() int {
 	return http.StatusBadRequest
 }
 
+// Error returns the code and the name of invalid fields,
+// so ErrorForm can be used as error
+func (formError ErrorForm) Error() string {
+	code := formError.Code
+	if code == "" {
+		code = "form_error"
+	}
+	fields := make([]string, 0)
+	for k, v := range formError.FieldError {
+		if v.IsError() {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	if formError.NonFieldError.IsError() {
+		fields = append(fields, "_error")
+	}
+	if len(fields) == 0 {
+		return code
+	}
+	return code + ": invalid " + strings.Join(fields, ", ")
+}
+
+// ToError converts err to Error that can be sent as response.
+// If err or any error it wraps is an Error, it is returned as is,
+// otherwise err is logged and ErrInternalServerError is returned.
+func ToError(err error) Error {
+	var heliosErr Error
+	if errors.As(err, &heliosErr) {
+		return heliosErr
+	}
+	App.Logger().Printf("error: %v", err)
+	return ErrInternalServerError
+}
+
 // ErrInternalServerError is general error that will be send
 // if there is unexpected error on the server
 var ErrInternalServerError = ErrorAPI{
@@ -172,16 +217,32 @@ var ErrInternalServerError = ErrorAPI{
 	Message:    "Error occured while processing the request",
 }
 
+// ErrNotFound returned when there is no route that matches the request url
+var ErrNotFound = ErrorAPI{
+	StatusCode: http.StatusNotFound,
+	Code:       "not_found",
+	Message:    "The requested url is not found",
+}
+
+// ErrMethodNotAllowed returned when the request url is found,
+// but not with the request method
+var ErrMethodNotAllowed = ErrorAPI{
+	StatusCode: http.StatusMethodNotAllowed,
+	Code:       "method_not_allowed",
+	Message:    "The request method is not allowed for the requested url",
+}
+
 // ErrUnsupportedContentType returned when request has content-type
 // header that is unsupported
 var ErrUnsupportedContentType = ErrorAPI{
 	StatusCode: http.StatusUnsupportedMediaType,
 	Code:       "unsupported_content_type",
-	Message:    "Currently, we are accepting application/json only",
+	Message:    "The Content-Type of the request is not supported",
 }
 
 // ErrJSONParseFailed will be returned when calling req.DeserializeRequestData
-// but with bad JSON. For example, int field that supplied with string
+// but with bad JSON. For example, missing closing bracket. Int field that
+// supplied with string is returned as ErrorForm instead.
 var ErrJSONParseFailed = ErrorAPI{
 	StatusCode: http.StatusBadRequest,
 	Code:       "failed_to_parse_json",
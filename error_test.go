@@ -2,6 +2,10 @@ package helios
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"testing"
 
@@ -102,3 +106,56 @@ func TestErrorForm(t *testing.T) {
 		assert.Equal(t, testCase.expectedJSON, string(jsonRepresentation))
 	}
 }
+
+// errorTestTeapot implements Error without Error method,
+// like the implementations written before Error was sent by HandleE
+type errorTestTeapot struct{}
+
+func (errorTestTeapot) GetMessage() map[string]interface{} {
+	return map[string]interface{}{"code": "teapot"}
+}
+
+func (errorTestTeapot) GetStatusCode() int {
+	return http.StatusTeapot
+}
+
+func TestErrorString(t *testing.T) {
+	App.BeforeTest()
+	App.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer App.SetLogger(nil)
+
+	formErr := NewErrorForm()
+	assert.Equal(t, "form_error", formErr.Error(), "Empty form error should only show the code")
+	formErr.FieldError["password"] = ErrorFormFieldAtomic{"password is too short"}
+	formErr.FieldError["email"] = ErrorFormFieldAtomic{"email is invalid"}
+	formErr.FieldError["name"] = ErrorFormFieldAtomic{}
+	formErr.NonFieldError = ErrorFormFieldAtomic{"password and email can't be the same"}
+	assert.Equal(t, "form_error: invalid email, password, _error", formErr.Error(), "Different form error string")
+	assert.Equal(t, "The requested url is not found", ErrNotFound.Error(), "Different api error string")
+
+	type toErrorTestCase struct {
+		err      error
+		expected Error
+	}
+	testCases := []toErrorTestCase{{
+		err:      ErrNotFound,
+		expected: ErrNotFound,
+	}, {
+		err:      formErr,
+		expected: formErr,
+	}, {
+		err:      fmt.Errorf("failed to load user: %w", ErrNotFound),
+		expected: ErrNotFound,
+	}, {
+		err:      errors.New("connection refused"),
+		expected: ErrInternalServerError,
+	}}
+	for i, testCase := range testCases {
+		assert.Equal(t, testCase.expected, ToError(testCase.err), "Different error on test case %d", i)
+	}
+	req := NewMockRequest()
+	HandleE(func(req Request) Error {
+		return errorTestTeapot{}
+	})(&req)
+	assert.Equal(t, http.StatusTeapot, req.StatusCode, "Error without Error method should be sent by HandleE")
+}
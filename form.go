@@ -0,0 +1,474 @@
+package helios
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MultipartConfig is the limits of multipart/form-data request
+type MultipartConfig struct {
+	// MaxMemory is the maximum bytes of uploaded files kept in memory,
+	// the rest of them are written to temporary files that are removed
+	// after the request is finished
+	MaxMemory int64
+
+	// MaxFileSize is the maximum bytes of each uploaded file
+	MaxFileSize int64
+
+	// MaxRequestSize is the maximum bytes of the whole request body
+	MaxRequestSize int64
+}
+
+// DefaultMultipartConfig returns 32 MB memory, 10 MB file, and 32 MB request limit
+func DefaultMultipartConfig() MultipartConfig {
+	return MultipartConfig{
+		MaxMemory:      32 << 20,
+		MaxFileSize:    10 << 20,
+		MaxRequestSize: 32 << 20,
+	}
+}
+
+// SetMultipartConfig sets the limits of multipart/form-data request
+func (app *Helios) SetMultipartConfig(config MultipartConfig) {
+	app.multipartConfig = &config
+}
+
+func (app *Helios) getMultipartConfig() MultipartConfig {
+	if app.multipartConfig == nil {
+		return DefaultMultipartConfig()
+	}
+	return *app.multipartConfig
+}
+
+// UploadedFile is a file uploaded by multipart/form-data request
+type UploadedFile struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+
+	fileHeader *multipart.FileHeader
+	content    []byte
+}
+
+// NewMockUploadedFile returns UploadedFile with the content,
+// to be used in MockRequest.Files
+func NewMockUploadedFile(filename string, contentType string, content []byte) *UploadedFile {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType)
+	return &UploadedFile{
+		Filename: filename,
+		Header:   header,
+		Size:     int64(len(content)),
+		content:  content,
+	}
+}
+
+// ContentType returns the content type of the file sent by the client
+func (file *UploadedFile) ContentType() string {
+	return file.Header.Get("Content-Type")
+}
+
+// Open returns the content of the file. It has to be closed after use.
+func (file *UploadedFile) Open() (multipart.File, error) {
+	if file.fileHeader != nil {
+		return file.fileHeader.Open()
+	}
+	return mockFile{bytes.NewReader(file.content)}, nil
+}
+
+type mockFile struct {
+	*bytes.Reader
+}
+
+func (mockFile) Close() error {
+	return nil
+}
+
+var uploadedFileType = reflect.TypeOf(&UploadedFile{})
+
+// ErrFormParseFailed will be returned when calling req.DeserializeRequestData
+// but with bad form-urlencoded or multipart body
+var ErrFormParseFailed = ErrorAPI{
+	StatusCode: http.StatusBadRequest,
+	Code:       "failed_to_parse_form",
+	Message:    "Failed to parse form request",
+}
+
+// ErrRequestTooLarge will be returned when the request body
+// is larger than the allowed size
+var ErrRequestTooLarge = ErrorAPI{
+	StatusCode: http.StatusRequestEntityTooLarge,
+	Code:       "request_too_large",
+	Message:    "The request body is too large",
+}
+
+// bodyLimiter reads at most n bytes from body, and
+// remembers whether the body is larger than that
+type bodyLimiter struct {
+	body     io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (l *bodyLimiter) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// check whether there is still more data
+		var b [1]byte
+		if n, _ := l.body.Read(b[:]); n > 0 {
+			l.exceeded = true
+			return 0, ErrRequestTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.body.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func (l *bodyLimiter) Close() error {
+	return l.body.Close()
+}
+
+// limitBody replaces the body of r with one that fails after n bytes,
+// or doesn't limit the body if n is not positive
+func limitBody(r *http.Request, n int64) *bodyLimiter {
+	if l, ok := r.Body.(*bodyLimiter); ok {
+		return l
+	}
+	if n <= 0 || r.Body == nil {
+		return &bodyLimiter{}
+	}
+	l := &bodyLimiter{body: r.Body, n: n}
+	r.Body = l
+	return l
+}
+
+// parseForm parses form-urlencoded body of the request
+func (req *HTTPRequest) parseForm() Error {
+	limiter := limitBody(req.r, App.getMultipartConfig().MaxRequestSize)
+	if err := req.r.ParseForm(); err != nil {
+		if limiter.exceeded {
+			return ErrRequestTooLarge
+		}
+		return ErrFormParseFailed
+	}
+	return nil
+}
+
+// parseMultipartForm parses multipart body of the request once
+func (req *HTTPRequest) parseMultipartForm() Error {
+	if req.r.MultipartForm != nil {
+		return nil
+	}
+	config := App.getMultipartConfig()
+	if config.MaxRequestSize > 0 && req.r.ContentLength > config.MaxRequestSize {
+		return ErrRequestTooLarge
+	}
+	limiter := limitBody(req.r, config.MaxRequestSize)
+	if err := req.r.ParseMultipartForm(config.MaxMemory); err != nil {
+		if limiter.exceeded {
+			return ErrRequestTooLarge
+		}
+		return ErrFormParseFailed
+	}
+	return nil
+}
+
+// removeUploadedFiles removes the temporary files of the multipart request.
+// net/http only removes them for the request passed to the server handler,
+// not for the copy made by Router with the url params in its context.
+func (req *HTTPRequest) removeUploadedFiles() {
+	if req.r.MultipartForm != nil {
+		req.r.MultipartForm.RemoveAll() // nolint:errcheck
+	}
+}
+
+// uploadedFiles returns the files of the multipart request
+func (req *HTTPRequest) uploadedFiles() map[string][]*UploadedFile {
+	files := make(map[string][]*UploadedFile)
+	if req.r.MultipartForm == nil {
+		return files
+	}
+	for key, fileHeaders := range req.r.MultipartForm.File {
+		for _, fileHeader := range fileHeaders {
+			files[key] = append(files[key], &UploadedFile{
+				Filename:   fileHeader.Filename,
+				Header:     fileHeader.Header,
+				Size:       fileHeader.Size,
+				fileHeader: fileHeader,
+			})
+		}
+	}
+	return files
+}
+
+// GetFile returns the file uploaded with the key by multipart/form-data
+// request. ErrorForm is returned if there is no file or it is larger
+// than MaxFileSize of the multipart config.
+func (req *HTTPRequest) GetFile(key string) (*UploadedFile, Error) {
+	if err := req.parseMultipartForm(); err != nil {
+		return nil, err
+	}
+	return getFile(req.uploadedFiles(), key)
+}
+
+// GetFile returns the file of the key in Files
+func (req *MockRequest) GetFile(key string) (*UploadedFile, Error) {
+	return getFile(req.Files, key)
+}
+
+// GetFormValue returns the first value of the key in form-urlencoded or
+// multipart/form-data body, or empty string if the body is not a form
+func (req *HTTPRequest) GetFormValue(key string) string {
+	mediaType, _, _ := mime.ParseMediaType(req.r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err := req.parseForm(); err == nil {
+			return req.r.PostForm.Get(key)
+		}
+	case "multipart/form-data":
+		if err := req.parseMultipartForm(); err == nil && len(req.r.MultipartForm.Value[key]) > 0 {
+			return req.r.MultipartForm.Value[key][0]
+		}
+	}
+	return ""
+}
+
+// GetFormValue returns the first value of the key if RequestData is url.Values
+func (req *MockRequest) GetFormValue(key string) string {
+	if values, ok := req.RequestData.(url.Values); ok {
+		return values.Get(key)
+	}
+	return ""
+}
+
+func getFile(files map[string][]*UploadedFile, key string) (*UploadedFile, Error) {
+	if len(files[key]) == 0 {
+		formErr := NewErrorForm()
+		formErr.FieldError[key] = ErrorFormFieldAtomic{"this field is required"}
+		return nil, formErr
+	}
+	file := files[key][0]
+	if err := checkFileSize(file); err != nil {
+		formErr := NewErrorForm()
+		formErr.FieldError[key] = ErrorFormFieldAtomic{err.Error()}
+		return nil, formErr
+	}
+	return file, nil
+}
+
+func checkFileSize(file *UploadedFile) error {
+	maxFileSize := App.getMultipartConfig().MaxFileSize
+	if maxFileSize > 0 && file.Size > maxFileSize {
+		return fmt.Errorf("must be at most %d bytes", maxFileSize)
+	}
+	return nil
+}
+
+// decodeForm fills struct pointed by obj with the values and files of form.
+// The name of a field is taken from form tag, or json tag if there is no form
+// tag. Field of nested struct is named with dot, e.g. address.city.
+func decodeForm(values url.Values, files map[string][]*UploadedFile, obj interface{}) Error {
+	return decodeValues("form", values, files, obj)
+}
+
+// decodeValues fills struct pointed by obj with the values and files, the
+// name of a field is taken from the tag, or json tag if there is no such tag
+func decodeValues(tag string, values url.Values, files map[string][]*UploadedFile, obj interface{}) Error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrFormParseFailed
+	}
+	formErr := NewErrorForm()
+	decodeFormStruct(tag, value.Elem(), "", values, files, formErr.FieldError)
+	if formErr.IsError() {
+		return formErr
+	}
+	return nil
+}
+
+func decodeFormStruct(tag string, value reflect.Value, prefix string, values url.Values, files map[string][]*UploadedFile, fieldErr ErrorFormFieldNested) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, ok := formFieldName(field, tag)
+		if !ok {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if field.Anonymous && field.Tag.Get(tag) == "" && field.Tag.Get("json") == "" && fieldValue.Kind() == reflect.Struct {
+			decodeFormStruct(tag, fieldValue, prefix, values, files, fieldErr)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		key := prefix + name
+
+		switch {
+		case field.Type == uploadedFileType:
+			if len(files[key]) > 0 {
+				if err := checkFileSize(files[key][0]); err != nil {
+					fieldErr[name] = ErrorFormFieldAtomic{err.Error()}
+					continue
+				}
+				fieldValue.Set(reflect.ValueOf(files[key][0]))
+			}
+		case field.Type == reflect.SliceOf(uploadedFileType):
+			if len(files[key]) > 0 {
+				for _, file := range files[key] {
+					if err := checkFileSize(file); err != nil {
+						fieldErr[name] = ErrorFormFieldAtomic{err.Error()}
+						break
+					}
+				}
+				fieldValue.Set(reflect.ValueOf(files[key]))
+			}
+		case isFormStruct(field.Type):
+			if !hasFormPrefix(values, files, key+".") {
+				continue
+			}
+			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() {
+					fieldValue.Set(reflect.New(field.Type.Elem()))
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			nested := make(ErrorFormFieldNested)
+			decodeFormStruct(tag, fieldValue, key+".", values, files, nested)
+			if nested.IsError() {
+				fieldErr[name] = nested
+			}
+		case field.Type.Kind() == reflect.Slice && !isTextUnmarshaler(field.Type):
+			formValues, ok := values[key]
+			if !ok {
+				continue
+			}
+			slice := reflect.MakeSlice(field.Type, len(formValues), len(formValues))
+			for j, formValue := range formValues {
+				if err := setFormValue(slice.Index(j), formValue); err != nil {
+					fieldErr[name] = ErrorFormFieldAtomic{err.Error()}
+					break
+				}
+			}
+			fieldValue.Set(slice)
+		default:
+			formValues, ok := values[key]
+			if !ok || len(formValues) == 0 {
+				continue
+			}
+			if err := setFormValue(fieldValue, formValues[0]); err != nil {
+				fieldErr[name] = ErrorFormFieldAtomic{err.Error()}
+			}
+		}
+	}
+}
+
+// formFieldName returns the name of field in the tag, e.g. form or query,
+// or false if the field is not decoded
+func formFieldName(field reflect.StructField, tag string) (string, bool) {
+	if value, ok := field.Tag.Lookup(tag); ok {
+		name := strings.Split(value, ",")[0]
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return jsonFieldName(field)
+}
+
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+// isFormStruct returns true if t is struct or pointer to struct
+// that is decoded field by field
+func isFormStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isTextUnmarshaler(t)
+}
+
+func hasFormPrefix(values url.Values, files map[string][]*UploadedFile, prefix string) bool {
+	for key := range values {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	for key := range files {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFormValue parses s according to the type of value
+func setFormValue(value reflect.Value, s string) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return setFormValue(value.Elem(), s)
+	}
+	if isTextUnmarshaler(value.Type()) {
+		if err := value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return errors.New("invalid value")
+		}
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		if s == "on" {
+			// checked checkbox without value
+			value.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		value.SetFloat(n)
+	default:
+		return errors.New("unsupported field type")
+	}
+	return nil
+}
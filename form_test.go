@@ -0,0 +1,192 @@
+package helios
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type formTestAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type formTestProfile struct {
+	Name      string           `form:"fullname" json:"name" validate:"required"`
+	Age       int              `json:"age"`
+	Score     *float64         `json:"score"`
+	Active    bool             `json:"active"`
+	Tags      []string         `json:"tags"`
+	Birthday  time.Time        `json:"birthday"`
+	Address   formTestAddress  `json:"address"`
+	Billing   *formTestAddress `json:"billing"`
+	Avatar    *UploadedFile    `json:"avatar"`
+	Documents []*UploadedFile  `json:"documents"`
+	Skipped   string           `form:"-"`
+}
+
+func TestDecodeForm(t *testing.T) {
+	App.BeforeTest()
+
+	values := url.Values{
+		"fullname":     {"Helios"},
+		"age":          {"21"},
+		"score":        {"9.5"},
+		"active":       {"on"},
+		"tags":         {"go", "web"},
+		"birthday":     {"2000-01-02T00:00:00Z"},
+		"address.city": {"Jakarta"},
+		"Skipped":      {"value"},
+	}
+	var profile formTestProfile
+	assert.Nil(t, decodeForm(values, nil, &profile), "Failed to decode form")
+	score := 9.5
+	expected := formTestProfile{
+		Name:     "Helios",
+		Age:      21,
+		Score:    &score,
+		Active:   true,
+		Tags:     []string{"go", "web"},
+		Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		Address:  formTestAddress{City: "Jakarta"},
+	}
+	assert.Equal(t, expected, profile, "Different decoded form")
+
+	values = url.Values{
+		"age":          {"twenty"},
+		"active":       {"maybe"},
+		"billing.city": {"Bandung"},
+	}
+	profile = formTestProfile{}
+	err := decodeForm(values, nil, &profile)
+	assert.NotNil(t, err, "Bad value should return error")
+	formErr := err.(ErrorForm)
+	assert.Equal(t, ErrorFormFieldAtomic{"must be an integer"}, formErr.FieldError["age"], "Different error of integer field")
+	assert.Equal(t, ErrorFormFieldAtomic{"must be a boolean"}, formErr.FieldError["active"], "Different error of boolean field")
+	assert.Equal(t, &formTestAddress{City: "Bandung"}, profile.Billing, "Nested pointer should be allocated")
+	assert.Equal(t, ErrFormParseFailed, decodeForm(values, nil, profile), "Non pointer should return error")
+}
+
+func TestMultipartUpload(t *testing.T) {
+	App.BeforeTest()
+	App.SetMultipartConfig(MultipartConfig{MaxMemory: 16, MaxFileSize: 64, MaxRequestSize: 1024})
+	defer func() { App.multipartConfig = nil }()
+
+	newRequest := func(files map[string]string) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("fullname", "Helios")
+		writer.WriteField("address.city", "Jakarta")
+		for name, content := range files {
+			part, _ := writer.CreateFormFile(name, name+".txt")
+			part.Write([]byte(content))
+		}
+		writer.Close()
+		request, _ := http.NewRequest("POST", "/", &body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+		return request
+	}
+
+	content := "content that is larger than max memory"
+	req := NewHTTPRequest(httptest.NewRecorder(), newRequest(map[string]string{"avatar": content, "documents": "doc"}))
+	var profile formTestProfile
+	assert.Nil(t, req.DeserializeRequestData(&profile), "Failed to deserialize multipart request")
+	assert.Equal(t, "Helios", profile.Name, "Different value field")
+	assert.NotNil(t, profile.Avatar, "File should be decoded to struct")
+	assert.Equal(t, 1, len(profile.Documents), "Files should be decoded to struct")
+
+	file, err := req.GetFile("avatar")
+	assert.Nil(t, err, "Failed to get uploaded file")
+	assert.Equal(t, "avatar.txt", file.Filename, "Different file name")
+	assert.Equal(t, int64(len(content)), file.Size, "Different file size")
+	f, _ := file.Open()
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	assert.Equal(t, content, string(data), "Different file content spilled to disk")
+
+	_, err = req.GetFile("missing")
+	assert.Equal(t, ErrorFormFieldAtomic{"this field is required"}, err.(ErrorForm).FieldError["missing"], "Missing file should return error")
+
+	large := string(make([]byte, 100))
+	req = NewHTTPRequest(httptest.NewRecorder(), newRequest(map[string]string{"avatar": large}))
+	_, err = req.GetFile("avatar")
+	assert.Equal(t, ErrorFormFieldAtomic{"must be at most 64 bytes"}, err.(ErrorForm).FieldError["avatar"], "Large file should return error")
+	err = req.DeserializeRequestData(&profile)
+	assert.Equal(t, ErrorFormFieldAtomic{"must be at most 64 bytes"}, err.(ErrorForm).FieldError["avatar"], "Large file should return error")
+
+	huge := string(make([]byte, 2048))
+	req = NewHTTPRequest(httptest.NewRecorder(), newRequest(map[string]string{"avatar": huge}))
+	assert.Equal(t, ErrRequestTooLarge, req.DeserializeRequestData(&profile), "Large request should return error")
+	request := newRequest(map[string]string{"avatar": huge})
+	request.ContentLength = -1
+	req = NewHTTPRequest(httptest.NewRecorder(), request)
+	_, err = req.GetFile("avatar")
+	assert.Equal(t, ErrRequestTooLarge, err, "Large request with unknown length should return error")
+}
+
+func TestMultipartUploadRemovesTempFiles(t *testing.T) {
+	App.BeforeTest()
+	App.SetMultipartConfig(MultipartConfig{MaxMemory: 16, MaxFileSize: 1024, MaxRequestSize: 4096})
+	defer func() { App.multipartConfig = nil }()
+
+	dir, _ := ioutil.TempDir("", "helios-multipart")
+	defer os.RemoveAll(dir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", dir)
+
+	app := &Helios{}
+	spilled := 0
+	app.POST("/upload/{id}", func(req Request) {
+		if _, err := req.GetFile("avatar"); err != nil {
+			req.SendError(err)
+			return
+		}
+		files, _ := ioutil.ReadDir(dir)
+		spilled = len(files)
+		req.SendJSON("ok", http.StatusOK)
+	})
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("avatar", "avatar.txt")
+	part.Write(bytes.Repeat([]byte("a"), 512))
+	writer.Close()
+	request := httptest.NewRequest("POST", "/upload/1", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	app.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code, "Failed to upload file")
+	assert.Equal(t, 1, spilled, "File larger than max memory should be written to temp dir")
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 0, len(files), "Temp files should be removed after the request")
+}
+
+func TestMockRequestForm(t *testing.T) {
+	App.BeforeTest()
+
+	req := NewMockRequest()
+	req.RequestData = url.Values{"fullname": {"Helios"}, "address.city": {""}}
+	req.Files["avatar"] = []*UploadedFile{NewMockUploadedFile("avatar.png", "image/png", []byte("png"))}
+
+	var profile formTestProfile
+	err := req.DeserializeRequestData(&profile)
+	assert.NotNil(t, err, "Form should be validated")
+	assert.Equal(t, ErrorFormFieldNested{"city": ErrorFormFieldAtomic{"this field is required"}}, err.(ErrorForm).FieldError["address"], "Different validation error")
+	assert.Equal(t, "Helios", profile.Name, "Different value field")
+	assert.Equal(t, "image/png", profile.Avatar.ContentType(), "Different content type")
+
+	file, err := req.GetFile("avatar")
+	assert.Nil(t, err, "Failed to get mock file")
+	f, _ := file.Open()
+	data, _ := ioutil.ReadAll(f)
+	assert.Nil(t, f.Close(), "Failed to close mock file")
+	assert.Equal(t, "png", string(data), "Different mock file content")
+}
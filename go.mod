@@ -3,8 +3,9 @@ module github.com/yonasadiel/helios
 go 1.13
 
 require (
-	github.com/gorilla/mux v1.7.4
-	github.com/gorilla/sessions v1.2.0
+	github.com/fxamacker/cbor/v2 v2.2.0
 	github.com/jinzhu/gorm v1.9.12
 	github.com/stretchr/testify v1.5.1
+	github.com/vmihailenco/msgpack v4.0.4+incompatible
+	golang.org/x/crypto v0.0.0-20191205180655-e7c4368fe9dd
 )
@@ -0,0 +1,109 @@
+package helios
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonTestAddress struct {
+	City    string `json:"city"`
+	ZipCode int    `json:"zipCode"`
+}
+
+type jsonTestUser struct {
+	Name    string          `json:"name"`
+	Age     int             `json:"age"`
+	Tags    []string        `json:"tags"`
+	Address jsonTestAddress `json:"address"`
+	Extra   interface{}     `json:"extra"`
+}
+
+func TestJSONOptions(t *testing.T) {
+	App.BeforeTest()
+	defer App.SetJSONOptions(JSONOptions{})
+
+	type jsonOptionsTestCase struct {
+		options       JSONOptions
+		body          string
+		expectedError Error
+	}
+	testCases := []jsonOptionsTestCase{{
+		options: JSONOptions{},
+		body:    `{"name":"helios","unknown":1} trailing`,
+	}, {
+		options:       JSONOptions{DisallowUnknownFields: true},
+		body:          `{"name":"helios","unknown":1}`,
+		expectedError: fieldFormError([]string{"unknown"}, "unknown field"),
+	}, {
+		options:       JSONOptions{DisallowTrailingData: true},
+		body:          `{"name":"helios"} {"name":"other"}`,
+		expectedError: ErrJSONParseFailed,
+	}, {
+		options: JSONOptions{DisallowTrailingData: true},
+		body:    "{\"name\":\"helios\"}\n",
+	}, {
+		options:       JSONOptions{MaxBodySize: 16},
+		body:          `{"name":"a very long name"}`,
+		expectedError: ErrRequestTooLarge,
+	}, {
+		options:       JSONOptions{MaxBodySize: 16},
+		body:          `{"name":"helios"}   `,
+		expectedError: ErrRequestTooLarge,
+	}, {
+		options: JSONOptions{MaxBodySize: 17},
+		body:    `{"name":"helios"}`,
+	}, {
+		options:       JSONOptions{},
+		body:          `{"age":"twenty"}`,
+		expectedError: fieldFormError([]string{"age"}, "must be an integer"),
+	}, {
+		options:       JSONOptions{},
+		body:          `{"address":{"zipCode":"12345"}}`,
+		expectedError: fieldFormError([]string{"address", "zipCode"}, "must be an integer"),
+	}, {
+		options:       JSONOptions{},
+		body:          `{"tags":"go"}`,
+		expectedError: fieldFormError([]string{"tags"}, "must be an array"),
+	}}
+	for i, testCase := range testCases {
+		App.SetJSONOptions(testCase.options)
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(testCase.body))
+		req := NewHTTPRequest(httptest.NewRecorder(), request)
+		var user jsonTestUser
+		err := req.DeserializeRequestData(&user)
+		assert.Equal(t, testCase.expectedError, err, "Different error on test case %d", i)
+		if err == nil {
+			assert.Equal(t, "helios", user.Name, "Different request data on test case %d", i)
+		}
+	}
+
+	App.SetJSONOptions(JSONOptions{UseNumber: true})
+	req := NewMockRequest()
+	req.RequestData = `{"extra":12345678901234567890}`
+	var user jsonTestUser
+	assert.Nil(t, req.DeserializeRequestData(&user), "Failed to deserialize with UseNumber")
+	assert.Equal(t, json.Number("12345678901234567890"), user.Extra, "Number should be decoded as json.Number")
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/", strings.NewReader(`{"address":{"city":1}}`))
+	Handle(HandleE(func(req Request) Error {
+		return req.DeserializeRequestData(&user)
+	}))(recorder, request)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Type mismatch should be bad request")
+	assert.Equal(t, `{"code":"form_error","message":{"_error":[],"address":{"city":["must be a string"]}}}`, recorder.Body.String(), "Type mismatch should name the field")
+}
+
+func TestJSONUnknownFieldMessage(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`{"address":{"country":"ID"}}`))
+	decoder.DisallowUnknownFields()
+	var user jsonTestUser
+	err := decoder.Decode(&user)
+	assert.NotNil(t, err, "Unknown field should return error")
+	assert.Equal(t, jsonUnknownFieldPrefix+`"country"`, err.Error(), "Message of unknown field is parsed by jsonDecodeError")
+	assert.Equal(t, fieldFormError([]string{"country"}, "unknown field"), jsonDecodeError(err), "Unknown field should be form error")
+}
@@ -0,0 +1,514 @@
+package helios
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWT algorithms supported by JWTKey
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// JWTKey is a key to sign or verify JWT, identified by ID that is written
+// as kid in the token header. Key is []byte for HS256, *rsa.PrivateKey or
+// *rsa.PublicKey for RS256, and ed25519.PrivateKey or ed25519.PublicKey for
+// EdDSA. Public key can only verify.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+func (key JWTKey) validate() error {
+	var ok bool
+	switch key.Algorithm {
+	case JWTAlgorithmHS256:
+		var secret []byte
+		secret, ok = key.Key.([]byte)
+		ok = ok && len(secret) > 0
+	case JWTAlgorithmRS256:
+		switch key.Key.(type) {
+		case *rsa.PrivateKey, *rsa.PublicKey:
+			ok = true
+		}
+	case JWTAlgorithmEdDSA:
+		switch k := key.Key.(type) {
+		case ed25519.PrivateKey:
+			ok = len(k) == ed25519.PrivateKeySize
+		case ed25519.PublicKey:
+			ok = len(k) == ed25519.PublicKeySize
+		}
+	default:
+		return fmt.Errorf("jwt key %q: unsupported algorithm %q", key.ID, key.Algorithm)
+	}
+	if !ok {
+		return fmt.Errorf("jwt key %q: invalid %T key for %s", key.ID, key.Key, key.Algorithm)
+	}
+	return nil
+}
+
+func (key JWTKey) canSign() bool {
+	switch key.Key.(type) {
+	case []byte, *rsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	}
+	return false
+}
+
+func (key JWTKey) sign(data []byte) ([]byte, error) {
+	switch k := key.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write(data) // nolint:errcheck
+		return mac.Sum(nil), nil
+	case *rsa.PrivateKey:
+		hashed := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hashed[:])
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, data), nil
+	}
+	return nil, fmt.Errorf("jwt key %q can't sign", key.ID)
+}
+
+func (key JWTKey) verify(data []byte, signature []byte) bool {
+	switch k := key.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write(data) // nolint:errcheck
+		return hmac.Equal(signature, mac.Sum(nil))
+	case *rsa.PrivateKey:
+		return JWTKey{Key: &k.PublicKey}.verify(data, signature)
+	case *rsa.PublicKey:
+		hashed := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed[:], signature) == nil
+	case ed25519.PrivateKey:
+		return ed25519.Verify(k.Public().(ed25519.PublicKey), data, signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, data, signature)
+	}
+	return false
+}
+
+// JWTConfig is the config of JWT
+type JWTConfig struct {
+	// Keys is the keys to sign and verify the token
+	Keys []JWTKey
+
+	// JWKSFile is the path of JSON Web Key Set whose keys are added to Keys,
+	// so the keys can be rotated by replacing the file and calling ReloadKeys
+	JWKSFile string
+
+	// SigningKeyID is the ID of the key used by Sign, the first key
+	// that can sign is used if it is empty
+	SigningKeyID string
+
+	// Issuer is set as iss claim by Sign, and checked by Verify if it is not empty
+	Issuer string
+
+	// Audience is set as aud claim by Sign, and checked by Verify if it is not empty
+	Audience string
+
+	// Leeway is the tolerance of clock difference on checking exp and nbf claims
+	Leeway time.Duration
+
+	// TTL is the lifetime of token signed by Sign without exp claim,
+	// the token doesn't expire if it is zero
+	TTL time.Duration
+}
+
+// JWTClaims is the payload of JWT
+type JWTClaims map[string]interface{}
+
+// Subject returns the sub claim
+func (claims JWTClaims) Subject() string {
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+// Audience returns the aud claim, which can be a string or an array of string
+func (claims JWTClaims) Audience() []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		audience := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	case []string:
+		return aud
+	}
+	return nil
+}
+
+// time returns the numeric date claim
+func (claims JWTClaims) time(name string) (time.Time, bool) {
+	switch value := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(value), 0), true
+	case int64:
+		return time.Unix(value, 0), true
+	case int:
+		return time.Unix(int64(value), 0), true
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return time.Unix(n, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ExpiresAt returns the exp claim, or false if the token doesn't expire
+func (claims JWTClaims) ExpiresAt() (time.Time, bool) {
+	return claims.time("exp")
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// JWT signs and verifies JSON Web Token
+type JWT struct {
+	config JWTConfig
+	mutex  sync.RWMutex
+	keys   []JWTKey
+}
+
+// NewJWT returns JWT with the config, loading the keys of JWKSFile
+func NewJWT(config JWTConfig) (*JWT, error) {
+	jwt := &JWT{config: config}
+	if err := jwt.ReloadKeys(); err != nil {
+		return nil, err
+	}
+	return jwt, nil
+}
+
+// ReloadKeys reads JWKSFile again, so the keys added to the file can be
+// used and the keys removed from the file can't be used anymore
+func (jwt *JWT) ReloadKeys() error {
+	keys := append([]JWTKey{}, jwt.config.Keys...)
+	if jwt.config.JWKSFile != "" {
+		fileKeys, err := LoadJWKSFile(jwt.config.JWKSFile)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	for _, key := range keys {
+		if err := key.validate(); err != nil {
+			return err
+		}
+	}
+	jwt.mutex.Lock()
+	defer jwt.mutex.Unlock()
+	jwt.keys = keys
+	return nil
+}
+
+func (jwt *JWT) signingKey() (JWTKey, error) {
+	jwt.mutex.RLock()
+	defer jwt.mutex.RUnlock()
+	for _, key := range jwt.keys {
+		if (jwt.config.SigningKeyID == "" || key.ID == jwt.config.SigningKeyID) && key.canSign() {
+			return key, nil
+		}
+	}
+	return JWTKey{}, errors.New("jwt: no key to sign")
+}
+
+// Sign returns the token of claims signed by the signing key. The iss, aud,
+// iat, and exp claims are filled from the config if they are not in claims.
+func (jwt *JWT) Sign(claims JWTClaims) (string, error) {
+	key, err := jwt.signingKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	payload := JWTClaims{"iat": now.Unix()}
+	if jwt.config.Issuer != "" {
+		payload["iss"] = jwt.config.Issuer
+	}
+	if jwt.config.Audience != "" {
+		payload["aud"] = jwt.config.Audience
+	}
+	if jwt.config.TTL > 0 {
+		payload["exp"] = now.Add(jwt.config.TTL).Unix()
+	}
+	for name, value := range claims {
+		payload[name] = value
+	}
+
+	header, err := json.Marshal(jwtHeader{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ErrJWTInvalid returned when the JWT is malformed, its signature
+// doesn't match, or its claims don't match the config. It has the
+// same code as ErrInvalidToken of API token.
+var ErrJWTInvalid = ErrorAPI{
+	StatusCode: http.StatusUnauthorized,
+	Code:       "invalid_token",
+	Message:    "Token is invalid",
+}
+
+// ErrJWTExpired returned when the JWT is past its exp claim
+var ErrJWTExpired = ErrorAPI{
+	StatusCode: http.StatusUnauthorized,
+	Code:       "token_expired",
+	Message:    "Token is expired",
+}
+
+// Verify returns the claims of token if its signature is made by one of the
+// keys and its claims are valid, or ErrJWTExpired or ErrJWTInvalid.
+// The key is chosen by the kid and alg of the token header.
+func (jwt *JWT) Verify(token string) (JWTClaims, Error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTInvalid
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, ErrJWTInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTInvalid
+	}
+	if !jwt.verifySignature(header, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrJWTInvalid
+	}
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrJWTInvalid
+	}
+	if err := jwt.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature checks signature with the key of header kid, or with
+// every key of header alg if the token doesn't have kid. The alg of the
+// key must match the header, so public key can't be used as HMAC secret.
+func (jwt *JWT) verifySignature(header jwtHeader, data []byte, signature []byte) bool {
+	jwt.mutex.RLock()
+	defer jwt.mutex.RUnlock()
+	for _, key := range jwt.keys {
+		if key.Algorithm != header.Algorithm || (header.KeyID != "" && key.ID != header.KeyID) {
+			continue
+		}
+		if key.verify(data, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func (jwt *JWT) validateClaims(claims JWTClaims) Error {
+	now := time.Now()
+	if _, ok := claims["exp"]; ok {
+		expiresAt, ok := claims.ExpiresAt()
+		if !ok {
+			return ErrJWTInvalid
+		}
+		if now.After(expiresAt.Add(jwt.config.Leeway)) {
+			return ErrJWTExpired
+		}
+	}
+	if _, ok := claims["nbf"]; ok {
+		notBefore, ok := claims.time("nbf")
+		if !ok || now.Add(jwt.config.Leeway).Before(notBefore) {
+			return ErrJWTInvalid
+		}
+	}
+	if jwt.config.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != jwt.config.Issuer {
+			return ErrJWTInvalid
+		}
+	}
+	if jwt.config.Audience != "" {
+		found := false
+		for _, audience := range claims.Audience() {
+			if audience == jwt.config.Audience {
+				found = true
+			}
+		}
+		if !found {
+			return ErrJWTInvalid
+		}
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+const jwtClaimsContextKey = "helios-jwt-claims"
+
+// CurrentJWTClaims returns the claims of JWT verified by JWT middleware,
+// or nil if the request is not authenticated by it
+func CurrentJWTClaims(req Request) JWTClaims {
+	claims, _ := req.GetContextData(jwtClaimsContextKey).(JWTClaims)
+	return claims
+}
+
+// CreateJWTMiddleware returns middleware that requires JWT sent as
+// Authorization: Bearer header and verified by jwt. The claims are put
+// in context data, retrievable by CurrentJWTClaims. It sends
+// ErrUnauthorized, ErrJWTExpired, or ErrJWTInvalid (401) with
+// WWW-Authenticate header.
+//     jwt, err := helios.NewJWT(helios.JWTConfig{JWKSFile: "jwks.json", Audience: "orders"})
+//     internal := helios.App.Group("/internal", helios.CreateJWTMiddleware(jwt))
+func CreateJWTMiddleware(jwt *JWT) Middleware {
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			token := bearerToken(req)
+			if token == "" {
+				req.SetHeader("WWW-Authenticate", "Bearer")
+				req.SendError(ErrUnauthorized)
+				return
+			}
+			claims, err := jwt.Verify(token)
+			if err != nil {
+				req.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+				req.SendError(err)
+				return
+			}
+			req.SetContextData(jwtClaimsContextKey, claims)
+			f(req)
+		}
+	}
+}
+
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv"`
+	K         string `json:"k"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	X         string `json:"x"`
+	D         string `json:"d"`
+}
+
+// LoadJWKSFile reads the keys of JSON Web Key Set file. It supports oct
+// keys for HS256, RSA public keys for RS256, and Ed25519 OKP keys for EdDSA.
+// Keys used for encryption, i.e. "use": "enc", are skipped.
+func LoadJWKSFile(path string) ([]JWTKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks %s: %w", path, err)
+	}
+	keys := make([]JWTKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		key, err := k.jwtKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks %s: key %q: %w", path, k.KeyID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (k jwk) jwtKey() (JWTKey, error) {
+	key := JWTKey{ID: k.KeyID, Algorithm: k.Algorithm}
+	switch k.KeyType {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return key, err
+		}
+		key.Key = secret
+		if key.Algorithm == "" {
+			key.Algorithm = JWTAlgorithmHS256
+		}
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return key, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return key, err
+		}
+		key.Key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.Algorithm == "" {
+			key.Algorithm = JWTAlgorithmRS256
+		}
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return key, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return key, err
+		}
+		key.Key = ed25519.PublicKey(x)
+		if k.D != "" {
+			d, err := base64.RawURLEncoding.DecodeString(k.D)
+			if err != nil {
+				return key, err
+			}
+			if len(d) != ed25519.SeedSize {
+				return key, errors.New("invalid ed25519 private key")
+			}
+			key.Key = ed25519.NewKeyFromSeed(d)
+		}
+		if key.Algorithm == "" {
+			key.Algorithm = JWTAlgorithmEdDSA
+		}
+	default:
+		return key, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+	return key, nil
+}
@@ -0,0 +1,195 @@
+package helios
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTSignVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := []JWTKey{
+		{ID: "hmac", Algorithm: JWTAlgorithmHS256, Key: []byte("0123456789abcdef0123456789abcdef")},
+		{ID: "rsa", Algorithm: JWTAlgorithmRS256, Key: rsaKey},
+		{ID: "ed", Algorithm: JWTAlgorithmEdDSA, Key: edKey},
+	}
+	for _, key := range keys {
+		jwt, err := NewJWT(JWTConfig{Keys: keys, SigningKeyID: key.ID, Issuer: "helios", Audience: "orders", TTL: time.Minute})
+		assert.Nil(t, err, "Failed to create JWT with %s", key.ID)
+
+		token, err := jwt.Sign(JWTClaims{"sub": "42", "role": "service"})
+		assert.Nil(t, err, "Failed to sign with %s", key.ID)
+		claims, verifyErr := jwt.Verify(token)
+		assert.Nil(t, verifyErr, "Failed to verify %s token", key.ID)
+		assert.Equal(t, "42", claims.Subject(), "Unexpected subject of %s token", key.ID)
+		assert.Equal(t, "service", claims["role"], "Unexpected custom claim of %s token", key.ID)
+		assert.Equal(t, []string{"orders"}, claims.Audience(), "Unexpected audience of %s token", key.ID)
+
+		parts := strings.Split(token, ".")
+		tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1"}`)) + "." + parts[2]
+		_, verifyErr = jwt.Verify(tampered)
+		assert.Equal(t, ErrJWTInvalid, verifyErr, "Tampered %s token should be invalid", key.ID)
+	}
+
+	publicOnly, _ := NewJWT(JWTConfig{Keys: []JWTKey{{ID: "rsa", Algorithm: JWTAlgorithmRS256, Key: &rsaKey.PublicKey}}})
+	_, err := publicOnly.Sign(JWTClaims{})
+	assert.NotNil(t, err, "Public key should not sign")
+
+	_, err = NewJWT(JWTConfig{Keys: []JWTKey{{ID: "bad", Algorithm: JWTAlgorithmHS256, Key: rsaKey}}})
+	assert.NotNil(t, err, "Key that doesn't match the algorithm should be rejected")
+	_, err = NewJWT(JWTConfig{Keys: []JWTKey{{ID: "none", Algorithm: "none", Key: []byte("x")}}})
+	assert.NotNil(t, err, "Unsupported algorithm should be rejected")
+}
+
+func TestJWTClaimsValidation(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	signer, _ := NewJWT(JWTConfig{Keys: []JWTKey{{ID: "k1", Algorithm: JWTAlgorithmHS256, Key: secret}}})
+	verifier, _ := NewJWT(JWTConfig{
+		Keys:     []JWTKey{{ID: "k1", Algorithm: JWTAlgorithmHS256, Key: secret}},
+		Issuer:   "helios",
+		Audience: "orders",
+		Leeway:   30 * time.Second,
+	})
+	now := time.Now()
+
+	type jwtClaimsTestCase struct {
+		claims        JWTClaims
+		expectedError Error
+	}
+	testCases := []jwtClaimsTestCase{
+		{claims: JWTClaims{"iss": "helios", "aud": "orders"}, expectedError: nil},
+		{claims: JWTClaims{"iss": "helios", "aud": []string{"billing", "orders"}}, expectedError: nil},
+		{claims: JWTClaims{"iss": "helios", "aud": "orders", "exp": now.Add(time.Minute).Unix()}, expectedError: nil},
+		{claims: JWTClaims{"iss": "helios", "aud": "orders", "exp": now.Add(-10 * time.Second).Unix()}, expectedError: nil},
+		{claims: JWTClaims{"iss": "helios", "aud": "orders", "exp": now.Add(-time.Minute).Unix()}, expectedError: ErrJWTExpired},
+		{claims: JWTClaims{"iss": "helios", "aud": "orders", "exp": "tomorrow"}, expectedError: ErrJWTInvalid},
+		{claims: JWTClaims{"iss": "helios", "aud": "orders", "nbf": now.Add(10 * time.Second).Unix()}, expectedError: nil},
+		{claims: JWTClaims{"iss": "helios", "aud": "orders", "nbf": now.Add(time.Minute).Unix()}, expectedError: ErrJWTInvalid},
+		{claims: JWTClaims{"iss": "other", "aud": "orders"}, expectedError: ErrJWTInvalid},
+		{claims: JWTClaims{"iss": "helios", "aud": "billing"}, expectedError: ErrJWTInvalid},
+		{claims: JWTClaims{"aud": "orders"}, expectedError: ErrJWTInvalid},
+	}
+	for i, testCase := range testCases {
+		token, _ := signer.Sign(testCase.claims)
+		_, err := verifier.Verify(token)
+		assert.Equal(t, testCase.expectedError, err, "Unexpected error on test case %d", i)
+	}
+
+	noneToken := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"helios","aud":"orders"}`)) + "."
+	_, err := verifier.Verify(noneToken)
+	assert.Equal(t, ErrJWTInvalid, err, "Unsigned token should be invalid")
+	_, err = verifier.Verify("not-a-token")
+	assert.Equal(t, ErrJWTInvalid, err, "Malformed token should be invalid")
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	oldKey := JWTKey{ID: "2020", Algorithm: JWTAlgorithmHS256, Key: []byte("old-secret-old-secret-old-secret")}
+	newKey := JWTKey{ID: "2021", Algorithm: JWTAlgorithmHS256, Key: []byte("new-secret-new-secret-new-secret")}
+
+	oldSigner, _ := NewJWT(JWTConfig{Keys: []JWTKey{oldKey}})
+	oldToken, _ := oldSigner.Sign(JWTClaims{"sub": "1"})
+	verifier, _ := NewJWT(JWTConfig{Keys: []JWTKey{newKey, oldKey}, SigningKeyID: "2021"})
+	newToken, _ := verifier.Sign(JWTClaims{"sub": "2"})
+	assert.True(t, strings.HasPrefix(newToken, base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT","kid":"2021"}`))), "Token should be signed with the signing key")
+
+	_, err := verifier.Verify(oldToken)
+	assert.Nil(t, err, "Token signed with old key should be verified")
+	_, err = verifier.Verify(newToken)
+	assert.Nil(t, err, "Token signed with new key should be verified")
+
+	unknownKid, _ := NewJWT(JWTConfig{Keys: []JWTKey{{ID: "2019", Algorithm: JWTAlgorithmHS256, Key: oldKey.Key}}})
+	unknownToken, _ := unknownKid.Sign(JWTClaims{})
+	_, err = verifier.Verify(unknownToken)
+	assert.Equal(t, ErrJWTInvalid, err, "Token with unknown kid should be invalid")
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPublic, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	encode := base64.RawURLEncoding.EncodeToString
+
+	dir, _ := ioutil.TempDir("", "helios-jwks")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	ioutil.WriteFile(path, []byte(fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": %q, "e": %q},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": %q},
+		{"kty": "oct", "kid": "hmac-1", "k": %q},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "", "e": ""}
+	]}`, encode(rsaKey.N.Bytes()), encode(big.NewInt(int64(rsaKey.E)).Bytes()), encode(edPublic), encode([]byte("0123456789abcdef0123456789abcdef")))), 0644)
+
+	keys, err := LoadJWKSFile(path)
+	assert.Nil(t, err, "Failed to load jwks")
+	assert.Equal(t, 3, len(keys), "Encryption key should be skipped")
+	assert.Equal(t, JWTAlgorithmRS256, keys[0].Algorithm, "RSA key should be RS256")
+	assert.Equal(t, JWTAlgorithmEdDSA, keys[1].Algorithm, "OKP key should be EdDSA")
+	assert.Equal(t, JWTAlgorithmHS256, keys[2].Algorithm, "oct key should be HS256")
+
+	verifier, err := NewJWT(JWTConfig{JWKSFile: path})
+	assert.Nil(t, err, "Failed to create JWT from jwks")
+	rsaSigner, _ := NewJWT(JWTConfig{Keys: []JWTKey{{ID: "rsa-1", Algorithm: JWTAlgorithmRS256, Key: rsaKey}}})
+	edSigner, _ := NewJWT(JWTConfig{Keys: []JWTKey{{ID: "ed-1", Algorithm: JWTAlgorithmEdDSA, Key: edKey}}})
+	rsaToken, _ := rsaSigner.Sign(JWTClaims{"sub": "rsa"})
+	edToken, _ := edSigner.Sign(JWTClaims{"sub": "ed"})
+	_, verifyErr := verifier.Verify(rsaToken)
+	assert.Nil(t, verifyErr, "RSA token should be verified by jwks key")
+	_, verifyErr = verifier.Verify(edToken)
+	assert.Nil(t, verifyErr, "EdDSA token should be verified by jwks key")
+
+	ioutil.WriteFile(path, []byte(`{"keys": []}`), 0644)
+	assert.Nil(t, verifier.ReloadKeys(), "Failed to reload jwks")
+	_, verifyErr = verifier.Verify(rsaToken)
+	assert.Equal(t, ErrJWTInvalid, verifyErr, "Token of removed key should be invalid")
+
+	ioutil.WriteFile(path, []byte(`{"keys": [{"kty": "EC", "kid": "ec-1"}]}`), 0644)
+	assert.NotNil(t, verifier.ReloadKeys(), "Unsupported key type should return error")
+	_, err = NewJWT(JWTConfig{JWKSFile: filepath.Join(dir, "missing.json")})
+	assert.NotNil(t, err, "Missing jwks file should return error")
+}
+
+func TestCreateJWTMiddleware(t *testing.T) {
+	jwt, _ := NewJWT(JWTConfig{Keys: []JWTKey{{ID: "k1", Algorithm: JWTAlgorithmHS256, Key: []byte("0123456789abcdef0123456789abcdef")}}})
+	validToken, _ := jwt.Sign(JWTClaims{"sub": "billing-service"})
+	expiredToken, _ := jwt.Sign(JWTClaims{"sub": "billing-service", "exp": time.Now().Add(-time.Hour).Unix()})
+	handler := CreateJWTMiddleware(jwt)(func(req Request) {
+		req.SendJSON(CurrentJWTClaims(req).Subject(), http.StatusOK)
+	})
+
+	type jwtMiddlewareTestCase struct {
+		authorization    string
+		expectedCode     int
+		expectedResponse string
+	}
+	testCases := []jwtMiddlewareTestCase{
+		{authorization: "", expectedCode: http.StatusUnauthorized, expectedResponse: `{"code":"unauthorized","message":"Authentication credentials were not provided or are invalid"}`},
+		{authorization: "Bearer " + validToken, expectedCode: http.StatusOK, expectedResponse: `"billing-service"`},
+		{authorization: "Bearer " + expiredToken, expectedCode: http.StatusUnauthorized, expectedResponse: `{"code":"token_expired","message":"Token is expired"}`},
+		{authorization: "Bearer " + validToken + "x", expectedCode: http.StatusUnauthorized, expectedResponse: `{"code":"invalid_token","message":"Token is invalid"}`},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		if testCase.authorization != "" {
+			req.RequestHeader["authorization"] = testCase.authorization
+		}
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+		assert.Equal(t, testCase.expectedResponse, string(req.JSONResponse), "Unexpected response on test case %d", i)
+		if testCase.expectedCode == http.StatusUnauthorized {
+			assert.Contains(t, req.ResponseHeader["WWW-Authenticate"], "Bearer", "WWW-Authenticate should be sent on test case %d", i)
+		}
+	}
+}
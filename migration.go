@@ -0,0 +1,187 @@
+package helios
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is a versioned change of the database. Migrations are applied
+// in the order of their name, so the name should start with sortable
+// version, e.g. 0001_create_user or 20200315120000_add_email_to_user.
+type Migration struct {
+	Name string
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+}
+
+// MigrationRecord is the history of applied migration,
+// kept in helios_migrations table
+type MigrationRecord struct {
+	ID        uint   `gorm:"primary_key"`
+	Name      string `gorm:"size:255;unique_index"`
+	AppliedAt time.Time
+}
+
+// TableName returns the table name of MigrationRecord
+func (MigrationRecord) TableName() string {
+	return "helios_migrations"
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// ErrMigrationDiverged returned if the applied migrations don't match the
+// registered migrations, e.g. applied migration is removed from the code,
+// or new migration is inserted before the applied one
+var ErrMigrationDiverged = errors.New("applied migrations diverge from registered migrations")
+
+// ErrMigrationIrreversible returned when reverting migration without Down
+var ErrMigrationIrreversible = errors.New("migration can't be reverted")
+
+// RegisterMigration adds migration to be applied by MigrateUp
+func (app *Helios) RegisterMigration(migration Migration) {
+	app.migrations = append(app.migrations, migration)
+}
+
+// LoadSQLMigrations registers the migrations written as sql files in dir.
+// Each migration consists of <name>.up.sql file and optional <name>.down.sql
+// file. Each file is executed as a whole, so the driver has to support
+// multiple statements in one query if the file contains more than one.
+func (app *Helios) LoadSQLMigrations(dir string) error {
+	upFiles, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	for _, upFile := range upFiles {
+		name := strings.TrimSuffix(filepath.Base(upFile), ".up.sql")
+		upSQL, err := ioutil.ReadFile(upFile)
+		if err != nil {
+			return err
+		}
+		migration := Migration{Name: name, Up: execSQL(string(upSQL))}
+
+		downSQL, err := ioutil.ReadFile(filepath.Join(dir, name+".down.sql"))
+		if err == nil {
+			migration.Down = execSQL(string(downSQL))
+		}
+		app.RegisterMigration(migration)
+	}
+	return nil
+}
+
+func execSQL(query string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(query).Error
+	}
+}
+
+// MigrateUp applies all migrations that haven't been applied, in order.
+// Each migration is run in its own transaction.
+func (app *Helios) MigrateUp() error {
+	migrations, applied, err := app.loadMigrations()
+	if err != nil {
+		return err
+	}
+	db := app.WriteDB()
+	for _, migration := range migrations[len(applied):] {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&MigrationRecord{Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %v", migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations, latest first
+func (app *Helios) MigrateDown(steps int) error {
+	migrations, applied, err := app.loadMigrations()
+	if err != nil {
+		return err
+	}
+	db := app.WriteDB()
+	for i := len(applied) - 1; i >= 0 && i >= len(applied)-steps; i-- {
+		migration := migrations[i]
+		if migration.Down == nil {
+			return fmt.Errorf("migration %s: %w", migration.Name, ErrMigrationIrreversible)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&applied[i]).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %v", migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// MigrationStatus returns all registered migrations in order,
+// along with whether they have been applied
+func (app *Helios) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, applied, err := app.loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(migrations))
+	for i, migration := range migrations {
+		s := MigrationStatus{Name: migration.Name}
+		if i < len(applied) {
+			s.Applied = true
+			s.AppliedAt = applied[i].AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// loadMigrations returns the registered migrations sorted by name and the
+// applied migrations in order of application. It returns ErrMigrationDiverged
+// if the applied migrations are not the prefix of the registered migrations.
+func (app *Helios) loadMigrations() ([]Migration, []MigrationRecord, error) {
+	migrations := make([]Migration, len(app.migrations))
+	copy(migrations, app.migrations)
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Name < migrations[j].Name
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Name == migrations[i-1].Name {
+			return nil, nil, fmt.Errorf("migration %s is registered more than once", migrations[i].Name)
+		}
+	}
+
+	db := app.WriteDB()
+	if err := db.AutoMigrate(&MigrationRecord{}).Error; err != nil {
+		return nil, nil, err
+	}
+	var applied []MigrationRecord
+	if err := db.Order("id").Find(&applied).Error; err != nil {
+		return nil, nil, err
+	}
+	for i, record := range applied {
+		if i >= len(migrations) {
+			return nil, nil, fmt.Errorf("%w: %s is applied but not registered", ErrMigrationDiverged, record.Name)
+		}
+		if migrations[i].Name != record.Name {
+			return nil, nil, fmt.Errorf("%w: expected %s to be applied, found %s", ErrMigrationDiverged, migrations[i].Name, record.Name)
+		}
+	}
+	return migrations, applied, nil
+}
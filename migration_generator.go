@@ -0,0 +1,283 @@
+package helios
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SchemaChangeKind is the kind of SchemaChange
+type SchemaChangeKind string
+
+// Kinds of SchemaChange
+const (
+	SchemaCreateTable SchemaChangeKind = "create_table"
+	SchemaAddColumn   SchemaChangeKind = "add_column"
+	SchemaDropColumn  SchemaChangeKind = "drop_column"
+)
+
+// SchemaChange is a change needed to make the database schema
+// match the registered models
+type SchemaChange struct {
+	Kind        SchemaChangeKind
+	Table       string
+	Column      string
+	UpSQL       []string
+	DownSQL     []string
+	Destructive bool
+}
+
+// String returns human readable description of the change
+func (change SchemaChange) String() string {
+	switch change.Kind {
+	case SchemaCreateTable:
+		return "create table " + change.Table
+	case SchemaAddColumn:
+		return "add column " + change.Table + "." + change.Column
+	default:
+		return "drop column " + change.Table + "." + change.Column
+	}
+}
+
+// GenerateMigrationOptions is the options of GenerateMigration
+type GenerateMigrationOptions struct {
+	// Dir is the directory where the sql files are written
+	Dir string
+
+	// Name is the name of migration, it will be prefixed by timestamp
+	Name string
+
+	// AllowDestructive has to be set to generate migration that
+	// loses data, e.g. dropping column
+	AllowDestructive bool
+}
+
+// ErrDestructiveMigration returned by GenerateMigration if the migration
+// loses data but it is not allowed by the options
+var ErrDestructiveMigration = errors.New("migration contains destructive changes")
+
+// DiffSchema compares the registered models with the schema of the write
+// database, and returns the changes needed to make the schema match the models.
+// Columns that exist on the database but not on the model are dropped.
+// Column type changes are not detected.
+func (app *Helios) DiffSchema() ([]SchemaChange, error) {
+	db := app.WriteDB()
+	changes := make([]SchemaChange, 0)
+	for _, model := range app.models {
+		scope := db.NewScope(model)
+		tableName := scope.TableName()
+		if !scope.Dialect().HasTable(tableName) {
+			changes = append(changes, createTableChange(scope))
+			continue
+		}
+
+		columns, err := tableColumns(db, tableName)
+		if err != nil {
+			return nil, err
+		}
+		modelColumns := make(map[string]bool)
+		for _, field := range scope.GetModelStruct().StructFields {
+			if !field.IsNormal {
+				continue
+			}
+			modelColumns[field.DBName] = true
+			if _, ok := columns[field.DBName]; !ok {
+				changes = append(changes, addColumnChange(scope, field))
+			}
+		}
+
+		dropped := make([]string, 0)
+		for column := range columns {
+			if !modelColumns[column] {
+				dropped = append(dropped, column)
+			}
+		}
+		sort.Strings(dropped)
+		for _, column := range dropped {
+			changes = append(changes, dropColumnChange(scope, column, columns[column]))
+		}
+	}
+	return changes, nil
+}
+
+// GenerateMigration writes the changes from DiffSchema as sql migration
+// files that can be loaded by LoadSQLMigrations. It returns the paths of
+// written files, or empty slice if there is no change.
+func (app *Helios) GenerateMigration(options GenerateMigrationOptions) ([]string, error) {
+	changes, err := app.DiffSchema()
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return []string{}, nil
+	}
+
+	destructive := make([]string, 0)
+	for _, change := range changes {
+		if change.Destructive {
+			destructive = append(destructive, change.String())
+		}
+	}
+	if len(destructive) > 0 && !options.AllowDestructive {
+		return nil, fmt.Errorf("%w: %s", ErrDestructiveMigration, strings.Join(destructive, ", "))
+	}
+
+	var up, down strings.Builder
+	up.WriteString("-- Generated by helios\n")
+	down.WriteString("-- Generated by helios\n")
+	for _, change := range changes {
+		writeChangeSQL(&up, change, change.UpSQL)
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		writeChangeSQL(&down, changes[i], changes[i].DownSQL)
+	}
+
+	name := options.Name
+	if name == "" {
+		name = "auto"
+	}
+	name = time.Now().UTC().Format("20060102150405") + "_" + name
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, err
+	}
+	upFile := filepath.Join(options.Dir, name+".up.sql")
+	downFile := filepath.Join(options.Dir, name+".down.sql")
+	if err := ioutil.WriteFile(upFile, []byte(up.String()), 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(downFile, []byte(down.String()), 0644); err != nil {
+		return nil, err
+	}
+	return []string{upFile, downFile}, nil
+}
+
+func writeChangeSQL(builder *strings.Builder, change SchemaChange, statements []string) {
+	builder.WriteString("\n-- " + change.String())
+	if change.Destructive {
+		builder.WriteString(" (destructive)")
+	}
+	builder.WriteString("\n")
+	for _, statement := range statements {
+		builder.WriteString(statement + ";\n")
+	}
+}
+
+// tableColumns returns the columns of table mapped to its database type
+func tableColumns(db *gorm.DB, tableName string) (map[string]string, error) {
+	rows, err := db.Table(tableName).Limit(0).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]string)
+	for _, columnType := range columnTypes {
+		columns[columnType.Name()] = columnType.DatabaseTypeName()
+	}
+	return columns, nil
+}
+
+func createTableChange(scope *gorm.Scope) SchemaChange {
+	var columns []string
+	var primaryKeys []string
+	primaryKeyInColumnType := false
+	for _, field := range scope.GetModelStruct().StructFields {
+		if !field.IsNormal {
+			continue
+		}
+		sqlType := scope.Dialect().DataTypeOf(field)
+		if strings.Contains(strings.ToLower(sqlType), "primary key") {
+			primaryKeyInColumnType = true
+		}
+		columns = append(columns, scope.Quote(field.DBName)+" "+sqlType)
+		if field.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, scope.Quote(field.DBName))
+		}
+	}
+	if len(primaryKeys) > 0 && !primaryKeyInColumnType {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ",")))
+	}
+
+	up := []string{fmt.Sprintf("CREATE TABLE %s (%s)", scope.QuotedTableName(), strings.Join(columns, ","))}
+	up = append(up, createIndexStatements(scope)...)
+	return SchemaChange{
+		Kind:    SchemaCreateTable,
+		Table:   scope.TableName(),
+		UpSQL:   up,
+		DownSQL: []string{fmt.Sprintf("DROP TABLE %s", scope.QuotedTableName())},
+	}
+}
+
+// createIndexStatements returns the index of the model declared
+// with gorm index and unique_index tags
+func createIndexStatements(scope *gorm.Scope) []string {
+	type index struct {
+		unique  bool
+		columns []string
+	}
+	indexes := make(map[string]*index)
+	names := make([]string, 0)
+	for _, field := range scope.GetModelStruct().StructFields {
+		for _, setting := range []string{"INDEX", "UNIQUE_INDEX"} {
+			value, ok := field.TagSettingsGet(setting)
+			if !ok {
+				continue
+			}
+			for _, name := range strings.Split(value, ",") {
+				if name == setting || name == "" {
+					kind := "idx"
+					if setting == "UNIQUE_INDEX" {
+						kind = "uix"
+					}
+					name = scope.Dialect().BuildKeyName(kind, scope.TableName(), field.DBName)
+				}
+				if _, ok := indexes[name]; !ok {
+					indexes[name] = &index{unique: setting == "UNIQUE_INDEX"}
+					names = append(names, name)
+				}
+				indexes[name].columns = append(indexes[name].columns, scope.Quote(field.DBName))
+			}
+		}
+	}
+
+	statements := make([]string, 0, len(names))
+	for _, name := range names {
+		createIndex := "CREATE INDEX"
+		if indexes[name].unique {
+			createIndex = "CREATE UNIQUE INDEX"
+		}
+		statements = append(statements, fmt.Sprintf("%s %s ON %s(%s)", createIndex, scope.Quote(name), scope.QuotedTableName(), strings.Join(indexes[name].columns, ", ")))
+	}
+	return statements
+}
+
+func addColumnChange(scope *gorm.Scope, field *gorm.StructField) SchemaChange {
+	return SchemaChange{
+		Kind:    SchemaAddColumn,
+		Table:   scope.TableName(),
+		Column:  field.DBName,
+		UpSQL:   []string{fmt.Sprintf("ALTER TABLE %s ADD %s %s", scope.QuotedTableName(), scope.Quote(field.DBName), scope.Dialect().DataTypeOf(field))},
+		DownSQL: []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", scope.QuotedTableName(), scope.Quote(field.DBName))},
+	}
+}
+
+func dropColumnChange(scope *gorm.Scope, column string, columnType string) SchemaChange {
+	return SchemaChange{
+		Kind:        SchemaDropColumn,
+		Table:       scope.TableName(),
+		Column:      column,
+		UpSQL:       []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", scope.QuotedTableName(), scope.Quote(column))},
+		DownSQL:     []string{fmt.Sprintf("ALTER TABLE %s ADD %s %s", scope.QuotedTableName(), scope.Quote(column), columnType)},
+		Destructive: true,
+	}
+}
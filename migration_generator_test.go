@@ -0,0 +1,106 @@
+package helios
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type generatorArticle struct {
+	ID    uint   `gorm:"primary_key"`
+	Title string `gorm:"index"`
+	Slug  string `gorm:"unique_index"`
+}
+
+type generatorComment struct {
+	ID   uint `gorm:"primary_key"`
+	Body string
+}
+
+func TestDiffSchema(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists("generator_articles", "generator_comments")
+	DB.Exec("CREATE TABLE generator_comments (id integer primary key, legacy varchar(255))")
+
+	app := &Helios{}
+	app.RegisterModel(generatorArticle{})
+	app.RegisterModel(generatorComment{})
+
+	changes, err := app.DiffSchema()
+	assert.Nil(t, err, "Failed to diff schema")
+	assert.Equal(t, 3, len(changes), "Different number of changes")
+
+	assert.Equal(t, SchemaCreateTable, changes[0].Kind, "Missing table should be created")
+	assert.Equal(t, "generator_articles", changes[0].Table, "Different table")
+	assert.Equal(t, 3, len(changes[0].UpSQL), "Create table should also create the indexes")
+	assert.Contains(t, changes[0].UpSQL[1], "idx_generator_articles_title", "Different index name")
+	assert.Contains(t, changes[0].UpSQL[2], "CREATE UNIQUE INDEX", "Unique index should be created")
+	assert.False(t, changes[0].Destructive, "Creating table is not destructive")
+
+	assert.Equal(t, SchemaAddColumn, changes[1].Kind, "Missing column should be added")
+	assert.Equal(t, "body", changes[1].Column, "Different column")
+	assert.False(t, changes[1].Destructive, "Adding column is not destructive")
+
+	assert.Equal(t, SchemaDropColumn, changes[2].Kind, "Unknown column should be dropped")
+	assert.Equal(t, "legacy", changes[2].Column, "Different column")
+	assert.True(t, changes[2].Destructive, "Dropping column is destructive")
+	assert.Equal(t, "drop column generator_comments.legacy", changes[2].String(), "Different description")
+
+	for _, change := range changes[:2] {
+		for _, statement := range change.UpSQL {
+			assert.Nil(t, DB.Exec(statement).Error, "Generated statement should be valid: %s", statement)
+		}
+	}
+	changes, _ = app.DiffSchema()
+	assert.Equal(t, 1, len(changes), "Applied changes should not be generated again")
+}
+
+func TestGenerateMigration(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&MigrationRecord{}, "generator_articles", "generator_comments")
+	DB.Exec("CREATE TABLE generator_comments (id integer primary key, legacy varchar(255))")
+
+	dir, _ := ioutil.TempDir("", "helios-migrations")
+	defer os.RemoveAll(dir)
+
+	app := &Helios{}
+	app.RegisterModel(generatorArticle{})
+	app.RegisterModel(generatorComment{})
+
+	_, err := app.GenerateMigration(GenerateMigrationOptions{Dir: dir, Name: "initial"})
+	assert.True(t, errors.Is(err, ErrDestructiveMigration), "Destructive migration should need confirmation")
+	assert.Contains(t, err.Error(), "drop column generator_comments.legacy", "Error should list the destructive changes")
+
+	files, err := app.GenerateMigration(GenerateMigrationOptions{Dir: dir, Name: "initial", AllowDestructive: true})
+	assert.Nil(t, err, "Failed to generate migration")
+	assert.Equal(t, 2, len(files), "Up and down files should be generated")
+	assert.True(t, strings.HasSuffix(files[0], "_initial.up.sql"), "Different up file name")
+	assert.True(t, strings.HasSuffix(files[1], "_initial.down.sql"), "Different down file name")
+	upSQL, _ := ioutil.ReadFile(files[0])
+	downSQL, _ := ioutil.ReadFile(files[1])
+	assert.Contains(t, string(upSQL), "-- drop column generator_comments.legacy (destructive)", "Destructive change should be marked")
+	assert.True(t, strings.Index(string(downSQL), "generator_comments") < strings.Index(string(downSQL), "generator_articles"), "Down should revert the changes in reverse order")
+	os.Remove(files[0])
+	os.Remove(files[1])
+
+	// the bundled sqlite can't drop column, so the generated migration is applied without it
+	DB.DropTable("generator_comments")
+	files, err = app.GenerateMigration(GenerateMigrationOptions{Dir: dir, Name: "initial"})
+	assert.Nil(t, err, "Failed to generate migration")
+	assert.Nil(t, app.LoadSQLMigrations(dir), "Failed to load generated migration")
+	assert.Nil(t, app.MigrateUp(), "Failed to apply generated migration")
+	assert.True(t, DB.HasTable("generator_articles"), "Generated migration should create table")
+	assert.True(t, DB.HasTable("generator_comments"), "Generated migration should create table")
+
+	files, err = app.GenerateMigration(GenerateMigrationOptions{Dir: dir})
+	assert.Nil(t, err, "Failed to generate migration")
+	assert.Empty(t, files, "Schema that matches models should not generate migration")
+
+	assert.Nil(t, app.MigrateDown(1), "Failed to revert generated migration")
+	assert.False(t, DB.HasTable("generator_articles"), "Reverted migration should drop table")
+	assert.False(t, DB.HasTable("generator_comments"), "Reverted migration should drop table")
+}
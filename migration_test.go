@@ -0,0 +1,117 @@
+package helios
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTableMigration(name string, table string) Migration {
+	return Migration{
+		Name: name,
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TABLE " + table + " (id integer)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE " + table).Error
+		},
+	}
+}
+
+func TestMigration(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&MigrationRecord{}, "migration_a", "migration_b", "migration_c")
+
+	app := &Helios{}
+	app.RegisterMigration(createTableMigration("0002_create_b", "migration_b"))
+	app.RegisterMigration(createTableMigration("0001_create_a", "migration_a"))
+
+	status, err := app.MigrationStatus()
+	assert.Nil(t, err, "Failed to get migration status")
+	assert.Equal(t, 2, len(status), "Different number of migrations")
+	assert.Equal(t, "0001_create_a", status[0].Name, "Migrations should be sorted by name")
+	assert.False(t, status[0].Applied, "Migration should not be applied yet")
+
+	assert.Nil(t, app.MigrateUp(), "Failed to migrate up")
+	assert.True(t, DB.HasTable("migration_a"), "Migration should be applied")
+	assert.True(t, DB.HasTable("migration_b"), "Migration should be applied")
+	status, _ = app.MigrationStatus()
+	assert.True(t, status[0].Applied, "Migration should be recorded")
+	assert.True(t, status[1].Applied, "Migration should be recorded")
+	assert.False(t, status[1].AppliedAt.IsZero(), "Migration should record applied time")
+
+	assert.Nil(t, app.MigrateUp(), "Migrating up twice should do nothing")
+
+	app.RegisterMigration(createTableMigration("0003_create_c", "migration_c"))
+	assert.Nil(t, app.MigrateUp(), "Failed to migrate up new migration")
+	assert.True(t, DB.HasTable("migration_c"), "New migration should be applied")
+
+	assert.Nil(t, app.MigrateDown(2), "Failed to migrate down")
+	assert.True(t, DB.HasTable("migration_a"), "Only the last migrations should be reverted")
+	assert.False(t, DB.HasTable("migration_b"), "Migration should be reverted")
+	assert.False(t, DB.HasTable("migration_c"), "Migration should be reverted")
+	status, _ = app.MigrationStatus()
+	assert.True(t, status[0].Applied, "Migration should still be applied")
+	assert.False(t, status[1].Applied, "Reverted migration should not be applied")
+
+	diverged := &Helios{}
+	diverged.RegisterMigration(createTableMigration("0001_create_x", "migration_x"))
+	_, err = diverged.MigrationStatus()
+	assert.True(t, errors.Is(err, ErrMigrationDiverged), "Different applied migration should diverge")
+	assert.True(t, errors.Is(diverged.MigrateUp(), ErrMigrationDiverged), "Diverged migrations should not be applied")
+	assert.False(t, DB.HasTable("migration_x"), "Diverged migrations should not be applied")
+
+	missing := &Helios{}
+	assert.True(t, errors.Is(missing.MigrateUp(), ErrMigrationDiverged), "Applied migration missing from code should diverge")
+
+	irreversible := &Helios{}
+	irreversible.RegisterMigration(Migration{Name: "0001_create_a", Up: func(tx *gorm.DB) error { return nil }})
+	assert.True(t, errors.Is(irreversible.MigrateDown(1), ErrMigrationIrreversible), "Migration without down can't be reverted")
+
+	duplicate := &Helios{}
+	duplicate.RegisterMigration(createTableMigration("0001_create_a", "migration_a"))
+	duplicate.RegisterMigration(createTableMigration("0001_create_a", "migration_a"))
+	assert.NotNil(t, duplicate.MigrateUp(), "Duplicate migration should return error")
+}
+
+func TestMigrationFailure(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&MigrationRecord{}, "migration_a")
+
+	app := &Helios{}
+	app.RegisterMigration(Migration{Name: "0001_failed", Up: func(tx *gorm.DB) error {
+		tx.Exec("CREATE TABLE migration_a (id integer)")
+		return errors.New("failed")
+	}})
+
+	assert.NotNil(t, app.MigrateUp(), "Failed migration should return error")
+	assert.False(t, DB.HasTable("migration_a"), "Failed migration should be rolled back")
+	status, _ := app.MigrationStatus()
+	assert.False(t, status[0].Applied, "Failed migration should not be recorded")
+}
+
+func TestLoadSQLMigrations(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&MigrationRecord{}, "sql_a", "sql_b")
+
+	dir, _ := ioutil.TempDir("", "helios-migrations")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "0001_sql_a.up.sql"), []byte("CREATE TABLE sql_a (id integer); CREATE TABLE sql_b (id integer);"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "0001_sql_a.down.sql"), []byte("DROP TABLE sql_a; DROP TABLE sql_b;"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "0002_irreversible.up.sql"), []byte("SELECT 1;"), 0644)
+
+	app := &Helios{}
+	assert.Nil(t, app.LoadSQLMigrations(dir), "Failed to load sql migrations")
+	assert.Equal(t, 2, len(app.migrations), "Different number of sql migrations")
+
+	assert.Nil(t, app.MigrateUp(), "Failed to apply sql migrations")
+	assert.True(t, DB.HasTable("sql_a"), "SQL migration should be applied")
+	assert.True(t, DB.HasTable("sql_b"), "SQL migration should be applied")
+
+	assert.True(t, errors.Is(app.MigrateDown(1), ErrMigrationIrreversible), "SQL migration without down file can't be reverted")
+}
@@ -0,0 +1,162 @@
+package helios
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes password to be stored, and verifies password
+// against the stored hash. Verify returns false for hash of other format.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded string, password string) bool
+}
+
+// BcryptHasher hashes password with bcrypt,
+// bcrypt.DefaultCost is used if Cost is zero
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns the bcrypt hash of password
+func (hasher BcryptHasher) Hash(password string) (string, error) {
+	cost := hasher.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Verify returns true if encoded is the bcrypt hash of password
+func (BcryptHasher) Verify(encoded string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+}
+
+// Argon2Hasher hashes password with argon2id. The hash is encoded as
+// $argon2id$v=19$m=65536,t=1,p=4$salt$key, so the params can be changed
+// without invalidating the stored hashes. The zero fields are filled
+// with the values recommended by golang.org/x/crypto/argon2.
+type Argon2Hasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen int
+}
+
+func (hasher Argon2Hasher) withDefaults() Argon2Hasher {
+	if hasher.Time == 0 {
+		hasher.Time = 1
+	}
+	if hasher.Memory == 0 {
+		hasher.Memory = 64 * 1024
+	}
+	if hasher.Threads == 0 {
+		hasher.Threads = 4
+	}
+	if hasher.KeyLen == 0 {
+		hasher.KeyLen = 32
+	}
+	if hasher.SaltLen == 0 {
+		hasher.SaltLen = 16
+	}
+	return hasher
+}
+
+// Hash returns the argon2id hash of password with random salt
+func (hasher Argon2Hasher) Hash(password string) (string, error) {
+	hasher = hasher.withDefaults()
+	salt := make([]byte, hasher.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, hasher.Time, hasher.Memory, hasher.Threads, hasher.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, hasher.Memory, hasher.Time, hasher.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify returns true if encoded is the argon2id hash of password,
+// using the params stored in encoded
+func (Argon2Hasher) Verify(encoded string, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	if memory == 0 || time == 0 || threads == 0 {
+		// argon2.IDKey panics on zero time or threads
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, actual) == 1
+}
+
+// ErrPasswordEmpty is returned when hashing empty password
+var ErrPasswordEmpty = errors.New("password is empty")
+
+// SetPasswordHasher sets the hasher used by HashPassword, BcryptHasher is used by default.
+// Password hashed by the other built-in hasher can still be checked, so the hasher
+// can be changed without resetting the passwords.
+func (app *Helios) SetPasswordHasher(hasher PasswordHasher) {
+	app.passwordHasher = hasher
+}
+
+func (app *Helios) passwordHashers() []PasswordHasher {
+	hashers := make([]PasswordHasher, 0, 3)
+	if app.passwordHasher != nil {
+		hashers = append(hashers, app.passwordHasher)
+	}
+	return append(hashers, BcryptHasher{}, Argon2Hasher{})
+}
+
+// HashPassword hashes password with the App password hasher
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", ErrPasswordEmpty
+	}
+	return App.passwordHashers()[0].Hash(password)
+}
+
+// CheckPassword returns true if encoded is the hash of password
+// by the App password hasher or one of the built-in hashers
+func CheckPassword(encoded string, password string) bool {
+	if encoded == "" || password == "" {
+		return false
+	}
+	for _, hasher := range App.passwordHashers() {
+		if hasher.Verify(encoded, password) {
+			return true
+		}
+	}
+	return false
+}
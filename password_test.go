@@ -0,0 +1,55 @@
+package helios
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		"bcrypt": BcryptHasher{Cost: 4},
+		"argon2": Argon2Hasher{Memory: 1024},
+	}
+	for name, hasher := range hashers {
+		hashed, err := hasher.Hash("secret-password")
+		assert.Nil(t, err, "Failed to hash with %s", name)
+		assert.NotContains(t, hashed, "secret-password", "Hash of %s should not contain the password", name)
+		assert.True(t, hasher.Verify(hashed, "secret-password"), "Failed to verify %s hash", name)
+		assert.False(t, hasher.Verify(hashed, "wrong-password"), "Wrong password should fail on %s", name)
+
+		hashedAgain, _ := hasher.Hash("secret-password")
+		assert.NotEqual(t, hashed, hashedAgain, "Hash of %s should be salted", name)
+	}
+
+	argon2Hashed, _ := Argon2Hasher{Memory: 1024, Time: 2, Threads: 1}.Hash("secret-password")
+	assert.True(t, strings.HasPrefix(argon2Hashed, "$argon2id$v=19$m=1024,t=2,p=1$"), "Argon2 hash should contain the params")
+	assert.True(t, Argon2Hasher{}.Verify(argon2Hashed, "secret-password"), "Argon2 should verify with the params in the hash")
+	assert.False(t, Argon2Hasher{}.Verify("$argon2id$v=19$m=1024,t=2,p=1$$", "secret-password"), "Malformed argon2 hash should fail")
+	assert.False(t, BcryptHasher{}.Verify(argon2Hashed, "secret-password"), "Bcrypt should not verify argon2 hash")
+
+	params := []string{"m=0,t=2,p=1", "m=1024,t=0,p=1", "m=1024,t=2,p=0"}
+	for _, param := range params {
+		zeroParam := strings.Replace(argon2Hashed, "m=1024,t=2,p=1", param, 1)
+		assert.False(t, Argon2Hasher{}.Verify(zeroParam, "secret-password"), "Argon2 hash with zero param %s should fail", param)
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	App.SetPasswordHasher(Argon2Hasher{Memory: 1024})
+	defer App.SetPasswordHasher(nil)
+
+	hashed, err := HashPassword("secret-password")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.True(t, strings.HasPrefix(hashed, "$argon2id$"), "Password should be hashed with the app hasher")
+	assert.True(t, CheckPassword(hashed, "secret-password"), "Failed to check password")
+
+	bcryptHashed, _ := BcryptHasher{Cost: 4}.Hash("old-password")
+	assert.True(t, CheckPassword(bcryptHashed, "old-password"), "Password hashed by built-in hasher should still be checked")
+	assert.False(t, CheckPassword(bcryptHashed, ""), "Empty password should not be checked")
+	assert.False(t, CheckPassword("", ""), "Empty hash should not be checked")
+
+	_, err = HashPassword("")
+	assert.Equal(t, ErrPasswordEmpty, err, "Empty password should not be hashed")
+}
@@ -0,0 +1,196 @@
+package helios
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Permission is the gorm model of permission, identified by codename
+// such as orders.delete. It has to be registered with App.RegisterModel
+// to be migrated.
+type Permission struct {
+	ID       uint   `gorm:"primary_key" json:"id"`
+	Codename string `gorm:"unique_index;size:100;not null" json:"codename"`
+	Name     string `gorm:"size:255" json:"name"`
+}
+
+// TableName returns the table name of Permission
+func (Permission) TableName() string {
+	return "helios_permissions"
+}
+
+// Group is the gorm model of group of users sharing the permissions.
+// It has to be registered with App.RegisterModel to be migrated.
+type Group struct {
+	ID          uint         `gorm:"primary_key" json:"id"`
+	Name        string       `gorm:"unique_index;size:150;not null" json:"name"`
+	Permissions []Permission `gorm:"many2many:helios_group_permissions" json:"permissions"`
+}
+
+// TableName returns the table name of Group
+func (Group) TableName() string {
+	return "helios_groups"
+}
+
+// PermissionCodenames returns the codenames of permissions granted
+// to user directly or through the groups of user
+func (user *User) PermissionCodenames(db *gorm.DB) ([]string, error) {
+	codenames := make([]string, 0)
+	err := db.Model(&Permission{}).
+		Where("id IN ? OR id IN ?",
+			db.Table("helios_user_permissions").Select("permission_id").Where("user_id = ?", user.ID).SubQuery(),
+			db.Table("helios_group_permissions").Select("helios_group_permissions.permission_id").
+				Joins("JOIN helios_user_groups ON helios_user_groups.group_id = helios_group_permissions.group_id").
+				Where("helios_user_groups.user_id = ?", user.ID).SubQuery(),
+		).
+		Pluck("codename", &codenames).Error
+	return codenames, err
+}
+
+// HasPermission returns true if user is active and is granted all of the
+// permissions, or user is active superuser
+func (user *User) HasPermission(db *gorm.DB, codenames ...string) (bool, error) {
+	if !user.IsActive {
+		return false, nil
+	}
+	if user.IsSuperuser {
+		return true, nil
+	}
+	granted, err := user.PermissionCodenames(db)
+	if err != nil {
+		return false, err
+	}
+	grantedSet := make(map[string]bool)
+	for _, codename := range granted {
+		grantedSet[codename] = true
+	}
+	for _, codename := range codenames {
+		if !grantedSet[codename] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ErrPermissionDenied returned when the user of request
+// doesn't have permission to do the action
+var ErrPermissionDenied = ErrorAPI{
+	StatusCode: http.StatusForbidden,
+	Code:       "permission_denied",
+	Message:    "You don't have permission to perform this action",
+}
+
+// RequirePermission returns middleware that sends ErrUnauthorized if the
+// request is anonymous, or ErrPermissionDenied if the user isn't granted
+// all of the permissions. The user is identified like RequireAuth.
+//     helios.App.DELETE("/orders/{id}", deleteOrder, helios.RequirePermission("orders.delete"))
+func RequirePermission(codenames ...string) Middleware {
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			user, err := currentUser(req)
+			if err != nil {
+				req.SendError(ToError(err))
+				return
+			}
+			if user == nil {
+				req.SendError(ErrUnauthorized)
+				return
+			}
+			allowed, err := user.HasPermission(req.DB(), codenames...)
+			if err != nil {
+				req.SendError(ToError(err))
+				return
+			}
+			if !allowed {
+				req.SendError(ErrPermissionDenied)
+				return
+			}
+			f(req)
+		}
+	}
+}
+
+// Policy decides whether user can view, edit, or delete obj. It is
+// registered for a model with App.RegisterPolicy, and consulted by
+// CanView, CanEdit, and CanDelete.
+type Policy interface {
+	CanView(user *User, obj interface{}) bool
+	CanEdit(user *User, obj interface{}) bool
+	CanDelete(user *User, obj interface{}) bool
+}
+
+// RegisterPolicy sets the policy of model, which is used
+// for both the model and the pointer to the model
+//     helios.App.RegisterPolicy(Order{}, OrderPolicy{})
+func (app *Helios) RegisterPolicy(model interface{}, policy Policy) {
+	if app.policies == nil {
+		app.policies = make(map[reflect.Type]Policy)
+	}
+	app.policies[modelType(model)] = policy
+}
+
+func (app *Helios) policy(obj interface{}) Policy {
+	return app.policies[modelType(obj)]
+}
+
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// can returns true for active superuser, false for anonymous or inactive user
+// and object without policy, and the decision of the policy otherwise
+func can(user *User, obj interface{}, decide func(policy Policy) bool) bool {
+	if user == nil || !user.IsActive {
+		return false
+	}
+	if user.IsSuperuser {
+		return true
+	}
+	policy := App.policy(obj)
+	if policy == nil {
+		return false
+	}
+	return decide(policy)
+}
+
+// CanView returns true if the policy of obj allows user to view it
+func CanView(user *User, obj interface{}) bool {
+	return can(user, obj, func(policy Policy) bool { return policy.CanView(user, obj) })
+}
+
+// CanEdit returns true if the policy of obj allows user to edit it
+func CanEdit(user *User, obj interface{}) bool {
+	return can(user, obj, func(policy Policy) bool { return policy.CanEdit(user, obj) })
+}
+
+// CanDelete returns true if the policy of obj allows user to delete it
+func CanDelete(user *User, obj interface{}) bool {
+	return can(user, obj, func(policy Policy) bool { return policy.CanDelete(user, obj) })
+}
+
+// Authorize checks obj against the user of request with check, e.g. CanEdit.
+// It returns ErrUnauthorized if the request is anonymous, ErrPermissionDenied
+// if check returns false, or nil if the user is allowed.
+//     if err := helios.Authorize(req, helios.CanEdit, &order); err != nil {
+//         req.SendError(err)
+//         return
+//     }
+func Authorize(req Request, check func(user *User, obj interface{}) bool, obj interface{}) Error {
+	user, err := currentUser(req)
+	if err != nil {
+		return ToError(err)
+	}
+	if user == nil {
+		return ErrUnauthorized
+	}
+	if !check(user, obj) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package helios
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type permissionTestOrder struct {
+	OwnerID uint
+}
+
+type permissionTestOrderPolicy struct{}
+
+func (permissionTestOrderPolicy) CanView(user *User, obj interface{}) bool {
+	return true
+}
+
+func (permissionTestOrderPolicy) CanEdit(user *User, obj interface{}) bool {
+	return obj.(*permissionTestOrder).OwnerID == user.ID
+}
+
+func (permissionTestOrderPolicy) CanDelete(user *User, obj interface{}) bool {
+	return false
+}
+
+// setupPermissionTest creates alice and bob with the permissions and groups
+// of orders, and returns the teardown of setupAuthTest
+func setupPermissionTest(t *testing.T) (*User, *User, func()) {
+	alice, teardown := setupAuthTest(t)
+	DB.AutoMigrate(Permission{}, Group{})
+	DB.Delete(Permission{})
+	DB.Delete(Group{})
+	DB.Exec("DELETE FROM helios_user_groups")
+	DB.Exec("DELETE FROM helios_user_permissions")
+	DB.Exec("DELETE FROM helios_group_permissions")
+
+	bob, _ := NewUser("bob", "bob@example.com", "secret-password")
+	DB.Create(bob)
+
+	view := Permission{Codename: "orders.view", Name: "Can view orders"}
+	remove := Permission{Codename: "orders.delete", Name: "Can delete orders"}
+	refund := Permission{Codename: "orders.refund", Name: "Can refund orders"}
+	DB.Create(&view)
+	DB.Create(&remove)
+	DB.Create(&refund)
+	staff := Group{Name: "staff", Permissions: []Permission{view, remove}}
+	DB.Create(&staff)
+
+	DB.Model(alice).Association("Groups").Append(&staff)
+	DB.Model(alice).Association("Permissions").Append(&refund)
+	DB.Model(bob).Association("Permissions").Append(&view)
+	return alice, bob, teardown
+}
+
+func TestHasPermission(t *testing.T) {
+	alice, bob, teardown := setupPermissionTest(t)
+	defer teardown()
+
+	codenames, err := alice.PermissionCodenames(DB)
+	assert.Nil(t, err, "Failed to get permissions")
+	assert.ElementsMatch(t, []string{"orders.view", "orders.delete", "orders.refund"}, codenames, "Permissions should include group and user permissions")
+
+	type hasPermissionTestCase struct {
+		user      *User
+		codenames []string
+		expected  bool
+	}
+	testCases := []hasPermissionTestCase{
+		{user: alice, codenames: []string{"orders.delete"}, expected: true},
+		{user: alice, codenames: []string{"orders.view", "orders.refund"}, expected: true},
+		{user: alice, codenames: []string{"orders.create"}, expected: false},
+		{user: bob, codenames: []string{"orders.view"}, expected: true},
+		{user: bob, codenames: []string{"orders.view", "orders.delete"}, expected: false},
+		{user: &User{ID: bob.ID, IsActive: true, IsSuperuser: true}, codenames: []string{"orders.create"}, expected: true},
+		{user: &User{ID: alice.ID, IsActive: false}, codenames: []string{"orders.view"}, expected: false},
+	}
+	for i, testCase := range testCases {
+		allowed, err := testCase.user.HasPermission(DB, testCase.codenames...)
+		assert.Nil(t, err, "Unexpected error on test case %d", i)
+		assert.Equal(t, testCase.expected, allowed, "Unexpected permission on test case %d", i)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	alice, bob, teardown := setupPermissionTest(t)
+	defer teardown()
+
+	handler := RequirePermission("orders.delete")(func(req Request) {
+		req.SendJSON("deleted", http.StatusOK)
+	})
+
+	req := NewMockRequest()
+	handler(&req)
+	assert.Equal(t, http.StatusUnauthorized, req.StatusCode, "Anonymous request should be unauthorized")
+
+	req = NewMockRequest()
+	req.SetUser(bob)
+	handler(&req)
+	assert.Equal(t, http.StatusForbidden, req.StatusCode, "User without permission should be denied")
+	assert.Equal(t, `{"code":"permission_denied","message":"You don't have permission to perform this action"}`, string(req.JSONResponse), "Unexpected response")
+
+	req = NewMockRequest()
+	req.SetUser(alice)
+	handler(&req)
+	assert.Equal(t, http.StatusOK, req.StatusCode, "User with permission should be allowed")
+	assert.Equal(t, alice, CurrentUser(&req), "User should be in context data")
+}
+
+func TestPolicy(t *testing.T) {
+	alice, bob, teardown := setupPermissionTest(t)
+	defer teardown()
+	defer func() { App.policies = nil }()
+	App.RegisterPolicy(permissionTestOrder{}, permissionTestOrderPolicy{})
+
+	order := &permissionTestOrder{OwnerID: alice.ID}
+	assert.True(t, CanView(bob, order), "Policy should allow viewing")
+	assert.True(t, CanEdit(alice, order), "Policy should allow owner to edit")
+	assert.False(t, CanEdit(bob, order), "Policy should deny other user to edit")
+	assert.False(t, CanDelete(alice, order), "Policy should deny deleting")
+	assert.True(t, CanDelete(&User{IsActive: true, IsSuperuser: true}, order), "Superuser should pass every policy")
+	assert.False(t, CanView(nil, order), "Anonymous user should not pass policy")
+	assert.False(t, CanView(alice, &User{}), "Object without policy should be denied")
+
+	type authorizeTestCase struct {
+		user          *User
+		expectedError Error
+	}
+	testCases := []authorizeTestCase{
+		{user: nil, expectedError: ErrUnauthorized},
+		{user: bob, expectedError: ErrPermissionDenied},
+		{user: alice, expectedError: nil},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		if testCase.user != nil {
+			req.SetUser(testCase.user)
+		}
+		assert.Equal(t, testCase.expectedError, Authorize(&req, CanEdit, order), "Unexpected error on test case %d", i)
+	}
+}
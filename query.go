@@ -0,0 +1,131 @@
+package helios
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// GetQueryParam returns the first value of the key in query string
+func (req *HTTPRequest) GetQueryParam(key string) string {
+	return req.r.URL.Query().Get(key)
+}
+
+// GetQueryParams returns all values of the key in query string,
+// e.g. [a b] for ?tag=a&tag=b
+func (req *HTTPRequest) GetQueryParams(key string) []string {
+	return req.r.URL.Query()[key]
+}
+
+// GetQueryParamInt returns the query param as int
+func (req *HTTPRequest) GetQueryParamInt(key string) (int, error) {
+	return queryParamInt(req.r.URL.Query(), key)
+}
+
+// GetQueryParamUint64 returns the query param as uint64
+func (req *HTTPRequest) GetQueryParamUint64(key string) (uint64, error) {
+	return queryParamUint64(req.r.URL.Query(), key)
+}
+
+// GetQueryParamBool returns the query param as bool,
+// accepting the values of strconv.ParseBool
+func (req *HTTPRequest) GetQueryParamBool(key string) (bool, error) {
+	return queryParamBool(req.r.URL.Query(), key)
+}
+
+// GetQueryParamTime returns the query param as time parsed with layout,
+// e.g. time.RFC3339
+func (req *HTTPRequest) GetQueryParamTime(key string, layout string) (time.Time, error) {
+	return queryParamTime(req.r.URL.Query(), key, layout)
+}
+
+// BindQuery fills struct pointed by obj with the query string, and
+// validates it like DeserializeRequestData. The name of a field is taken
+// from query tag, or json tag if there is no query tag. Repeated values are
+// decoded to slice. Values that can't be parsed are returned as ErrorForm.
+//     type ListUserQuery struct {
+//         Page   int       `query:"page" validate:"omitempty,min=1"`
+//         Tags   []string  `query:"tag"`
+//         Active *bool     `query:"active"`
+//         Since  time.Time `query:"since"`
+//     }
+func (req *HTTPRequest) BindQuery(obj interface{}) Error {
+	return bindQuery(req.r.URL.Query(), obj)
+}
+
+// GetQueryParam returns the first value of the key in QueryParam
+func (req *MockRequest) GetQueryParam(key string) string {
+	return req.QueryParam.Get(key)
+}
+
+// GetQueryParams returns all values of the key in QueryParam
+func (req *MockRequest) GetQueryParams(key string) []string {
+	return req.QueryParam[key]
+}
+
+// GetQueryParamInt returns the query param as int
+func (req *MockRequest) GetQueryParamInt(key string) (int, error) {
+	return queryParamInt(req.QueryParam, key)
+}
+
+// GetQueryParamUint64 returns the query param as uint64
+func (req *MockRequest) GetQueryParamUint64(key string) (uint64, error) {
+	return queryParamUint64(req.QueryParam, key)
+}
+
+// GetQueryParamBool returns the query param as bool
+func (req *MockRequest) GetQueryParamBool(key string) (bool, error) {
+	return queryParamBool(req.QueryParam, key)
+}
+
+// GetQueryParamTime returns the query param as time parsed with layout
+func (req *MockRequest) GetQueryParamTime(key string, layout string) (time.Time, error) {
+	return queryParamTime(req.QueryParam, key, layout)
+}
+
+// BindQuery fills struct pointed by obj with QueryParam
+func (req *MockRequest) BindQuery(obj interface{}) Error {
+	return bindQuery(req.QueryParam, obj)
+}
+
+// The typed query param getters return ErrorForm keyed by the query param,
+// so a bad value is sent as bad request like the errors of BindQuery
+
+func queryParamInt(query url.Values, key string) (int, error) {
+	param, err := strconv.Atoi(query.Get(key))
+	if err != nil {
+		return 0, fieldFormError([]string{key}, "must be an integer")
+	}
+	return param, nil
+}
+
+func queryParamUint64(query url.Values, key string) (uint64, error) {
+	param, err := strconv.ParseUint(query.Get(key), 10, 64)
+	if err != nil {
+		return 0, fieldFormError([]string{key}, "must be a positive integer")
+	}
+	return param, nil
+}
+
+func queryParamBool(query url.Values, key string) (bool, error) {
+	param, err := strconv.ParseBool(query.Get(key))
+	if err != nil {
+		return false, fieldFormError([]string{key}, "must be a boolean")
+	}
+	return param, nil
+}
+
+func queryParamTime(query url.Values, key string, layout string) (time.Time, error) {
+	param, err := time.Parse(layout, query.Get(key))
+	if err != nil {
+		return time.Time{}, fieldFormError([]string{key}, "must be a time formatted as "+layout)
+	}
+	return param, nil
+}
+
+func bindQuery(query url.Values, obj interface{}) Error {
+	if err := decodeValues("query", query, nil, obj); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
@@ -0,0 +1,113 @@
+package helios
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type queryTestFilter struct {
+	Page   int       `query:"page" json:"page" validate:"omitempty,min=1"`
+	Tags   []string  `query:"tag" json:"tags"`
+	Active *bool     `query:"active" json:"active"`
+	Since  time.Time `query:"since" json:"since"`
+	Search string    `json:"q"`
+}
+
+func TestQueryParam(t *testing.T) {
+	App.BeforeTest()
+
+	request, _ := http.NewRequest("GET", "/users?page=2&id=18446744073709551615&active=true&since=2020-01-02&tag=a&tag=b&bad=x", nil)
+	httpReq := NewHTTPRequest(httptest.NewRecorder(), request)
+	mockReq := NewMockRequest()
+	mockReq.QueryParam = request.URL.Query()
+
+	for _, req := range []Request{&httpReq, &mockReq} {
+		assert.Equal(t, "2", req.GetQueryParam("page"), "Different query param")
+		assert.Equal(t, "", req.GetQueryParam("missing"), "Missing query param should be empty")
+		assert.Equal(t, []string{"a", "b"}, req.GetQueryParams("tag"), "Different repeated query param")
+
+		page, err := req.GetQueryParamInt("page")
+		assert.Nil(t, err, "Failed to parse int query param")
+		assert.Equal(t, 2, page, "Different int query param")
+		id, err := req.GetQueryParamUint64("id")
+		assert.Nil(t, err, "Failed to parse uint64 query param")
+		assert.Equal(t, uint64(18446744073709551615), id, "Different uint64 query param")
+		active, err := req.GetQueryParamBool("active")
+		assert.Nil(t, err, "Failed to parse bool query param")
+		assert.True(t, active, "Different bool query param")
+		since, err := req.GetQueryParamTime("since", "2006-01-02")
+		assert.Nil(t, err, "Failed to parse time query param")
+		assert.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), since, "Different time query param")
+
+		_, err = req.GetQueryParamInt("bad")
+		assert.Equal(t, fieldFormError([]string{"bad"}, "must be an integer"), err, "Bad int query param should return error")
+		_, err = req.GetQueryParamUint64("missing")
+		assert.Equal(t, fieldFormError([]string{"missing"}, "must be a positive integer"), err, "Missing uint64 query param should return error")
+		_, err = req.GetQueryParamBool("bad")
+		assert.Equal(t, fieldFormError([]string{"bad"}, "must be a boolean"), err, "Bad bool query param should return error")
+		_, err = req.GetQueryParamTime("bad", time.RFC3339)
+		assert.Equal(t, fieldFormError([]string{"bad"}, "must be a time formatted as "+time.RFC3339), err, "Bad time query param should return error")
+	}
+
+	recorder := httptest.NewRecorder()
+	Handle(HandleE(func(req Request) Error {
+		page, err := req.GetQueryParamInt("page")
+		if err != nil {
+			return ToError(err)
+		}
+		req.SendJSON(page, http.StatusOK)
+		return nil
+	}))(recorder, httptest.NewRequest("GET", "/users?page=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Bad query param should be bad request")
+	assert.Equal(t, `{"code":"form_error","message":{"_error":[],"page":["must be an integer"]}}`, recorder.Body.String(), "Bad query param should name the key")
+}
+
+func TestBindQuery(t *testing.T) {
+	App.BeforeTest()
+
+	type bindQueryTestCase struct {
+		query         string
+		expected      queryTestFilter
+		expectedError ErrorFormFieldNested
+	}
+	active := false
+	testCases := []bindQueryTestCase{{
+		query: "page=3&tag=a&tag=b&active=false&since=2020-01-02T03:04:05Z&q=helios",
+		expected: queryTestFilter{
+			Page:   3,
+			Tags:   []string{"a", "b"},
+			Active: &active,
+			Since:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Search: "helios",
+		},
+	}, {
+		query:         "page=x&active=maybe&since=yesterday",
+		expectedError: ErrorFormFieldNested{"page": ErrorFormFieldAtomic{"must be an integer"}, "active": ErrorFormFieldAtomic{"must be a boolean"}, "since": ErrorFormFieldAtomic{"invalid value"}},
+	}, {
+		query:         "page=-1",
+		expectedError: ErrorFormFieldNested{"page": ErrorFormFieldAtomic{"must be at least 1"}},
+	}}
+	for i, testCase := range testCases {
+		request, _ := http.NewRequest("GET", "/users?"+testCase.query, nil)
+		httpReq := NewHTTPRequest(httptest.NewRecorder(), request)
+		mockReq := NewMockRequest()
+		mockReq.QueryParam, _ = url.ParseQuery(testCase.query)
+
+		for _, req := range []Request{&httpReq, &mockReq} {
+			var filter queryTestFilter
+			err := req.BindQuery(&filter)
+			if testCase.expectedError == nil {
+				assert.Nil(t, err, "Failed to bind query on test case %d", i)
+				assert.Equal(t, testCase.expected, filter, "Different bound query on test case %d", i)
+			} else {
+				assert.NotNil(t, err, "Bad query should return error on test case %d", i)
+				assert.Equal(t, testCase.expectedError, err.(ErrorForm).FieldError, "Different error on test case %d", i)
+			}
+		}
+	}
+}
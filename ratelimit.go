@@ -0,0 +1,367 @@
+package helios
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RateLimitStore keeps the state of rate limiters. Update has to be atomic,
+// so requests to different instances sharing the store are counted once.
+type RateLimitStore interface {
+	// Update calls fn with the state of key, or empty string if the key doesn't
+	// exist or is expired, and stores the returned state until ttl passes
+	Update(key string, ttl time.Duration, fn func(state string) string) error
+}
+
+const rateLimitShards = 32
+
+// MemoryRateLimitStore keeps the state of rate limiters in memory,
+// sharded by key to reduce lock contention. It is the default store.
+type MemoryRateLimitStore struct {
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mutex   sync.Mutex
+	entries map[string]rateLimitEntry
+	updates int
+}
+
+type rateLimitEntry struct {
+	state     string
+	expiresAt time.Time
+}
+
+// NewMemoryRateLimitStore returns new empty MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{}
+	for i := range store.shards {
+		store.shards[i].entries = make(map[string]rateLimitEntry)
+	}
+	return store
+}
+
+// Update calls fn with the state of key and stores the result. Expired
+// entries of the shard are removed once every 1024 updates.
+func (store *MemoryRateLimitStore) Update(key string, ttl time.Duration, fn func(state string) string) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(key)) // nolint:errcheck
+	shard := &store.shards[hash.Sum32()%rateLimitShards]
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	now := time.Now()
+	shard.updates++
+	if shard.updates%1024 == 0 {
+		for k, entry := range shard.entries {
+			if now.After(entry.expiresAt) {
+				delete(shard.entries, k)
+			}
+		}
+	}
+	state := ""
+	if entry, ok := shard.entries[key]; ok && !now.After(entry.expiresAt) {
+		state = entry.state
+	}
+	shard.entries[key] = rateLimitEntry{state: fn(state), expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// RateLimitModel is the gorm model of rate limiter state kept by DBRateLimitStore.
+// It has to be registered with App.RegisterModel to be migrated.
+type RateLimitModel struct {
+	Key       string    `gorm:"column:limit_key;primary_key;size:255"`
+	State     string    `gorm:"size:255"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table name of RateLimitModel
+func (RateLimitModel) TableName() string {
+	return "helios_rate_limits"
+}
+
+// DBRateLimitStore keeps the state of rate limiters in the App write database
+// using RateLimitModel, so the limit is shared by multiple instances of the app
+type DBRateLimitStore struct{}
+
+// NewDBRateLimitStore returns new DBRateLimitStore
+func NewDBRateLimitStore() *DBRateLimitStore {
+	return &DBRateLimitStore{}
+}
+
+// Update reads and writes the state of key in a transaction,
+// locking the row on databases that support SELECT FOR UPDATE
+func (*DBRateLimitStore) Update(key string, ttl time.Duration, fn func(state string) string) error {
+	tx := App.WriteDB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	query := tx
+	if tx.Dialect().GetName() != "sqlite3" {
+		query = tx.Set("gorm:query_option", "FOR UPDATE")
+	}
+	now := time.Now()
+	var stored RateLimitModel
+	if err := query.Where("limit_key = ?", key).First(&stored).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	state := ""
+	if stored.Key != "" && !now.After(stored.ExpiresAt) {
+		state = stored.State
+	}
+	if err := tx.Save(&RateLimitModel{Key: key, State: fn(state), ExpiresAt: now.Add(ttl)}).Error; err != nil {
+		return err
+	}
+	if stored.Key == "" {
+		// remove expired entries when a new key is added, so the table doesn't grow forever
+		if err := tx.Where("expires_at < ?", now).Delete(&RateLimitModel{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+var defaultRateLimitStore = NewMemoryRateLimitStore()
+
+// SetRateLimitStore sets the store used by rate limiters without their own
+// store, MemoryRateLimitStore is used by default
+func (app *Helios) SetRateLimitStore(store RateLimitStore) {
+	app.rateLimitStore = store
+}
+
+func (app *Helios) getRateLimitStore() RateLimitStore {
+	if app.rateLimitStore == nil {
+		return defaultRateLimitStore
+	}
+	return app.rateLimitStore
+}
+
+// Rate limit algorithms of RateLimitConfig
+const (
+	// RateLimitSlidingWindow counts the requests of the current window and the
+	// previous window weighted by its overlap with the sliding window
+	RateLimitSlidingWindow = "sliding_window"
+
+	// RateLimitTokenBucket refills Limit tokens every Window up to Burst,
+	// and takes a token for every request
+	RateLimitTokenBucket = "token_bucket"
+)
+
+// RateLimitConfig is the config of rate limit middleware
+type RateLimitConfig struct {
+	// Name separates the counter of the limiter from the other limiters.
+	// Limiters are numbered in order of creation if it is empty, so set it
+	// if the same limit has to be shared by multiple routes.
+	Name string
+
+	// Limit is the number of requests allowed every Window
+	Limit  int
+	Window time.Duration
+
+	// Algorithm is RateLimitSlidingWindow (the default) or RateLimitTokenBucket
+	Algorithm string
+
+	// Burst is the capacity of the token bucket, Limit is used if it is zero
+	Burst int
+
+	// Key returns the key the requests are counted by, RateLimitByIP by default.
+	// Request with empty key is not limited.
+	Key func(req Request) string
+
+	// Store keeps the counters, the App rate limit store is used if it is nil
+	Store RateLimitStore
+
+	// FailClosed sends ErrInternalServerError if the store fails,
+	// otherwise the error is logged and the request is allowed
+	FailClosed bool
+}
+
+// RateLimitByIP keys the requests by RemoteIP. Behind reverse proxies,
+// set them with App.SetTrustedProxies so the client is taken from
+// X-Forwarded-For, otherwise all requests are counted as the proxy.
+func RateLimitByIP(req Request) string {
+	return "ip:" + req.RemoteIP()
+}
+
+// RateLimitByUser keys the requests by CurrentUser,
+// or by RemoteIP if the request is anonymous
+func RateLimitByUser(req Request) string {
+	if user := CurrentUser(req); user != nil {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	return RateLimitByIP(req)
+}
+
+// ErrTooManyRequests returned when the rate limit is exceeded
+var ErrTooManyRequests = ErrorAPI{
+	StatusCode: http.StatusTooManyRequests,
+	Code:       "too_many_requests",
+	Message:    "Too many requests, try again later",
+}
+
+var rateLimiterCount int64
+
+// rateLimitResult is the outcome of a request against the limiter
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Time
+	retryAfter time.Duration
+}
+
+// CreateRateLimitMiddleware returns middleware that allows config.Limit requests
+// every config.Window for each key, and sends ErrTooManyRequests (429) with
+// Retry-After header afterwards. The response carries X-RateLimit-Limit,
+// X-RateLimit-Remaining, and X-RateLimit-Reset (unix time) headers. If the store
+// fails, the error is logged and the request is allowed, unless config.FailClosed.
+//     helios.App.POST("/login", loginHandler, helios.CreateRateLimitMiddleware(helios.RateLimitConfig{
+//         Limit:  5,
+//         Window: time.Minute,
+//     }))
+func CreateRateLimitMiddleware(config RateLimitConfig) Middleware {
+	if config.Limit <= 0 || config.Window <= 0 {
+		panic("helios: rate limit needs positive Limit and Window")
+	}
+	if config.Name == "" {
+		config.Name = "limiter" + strconv.FormatInt(atomic.AddInt64(&rateLimiterCount, 1), 10)
+	}
+	if config.Key == nil {
+		config.Key = RateLimitByIP
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+
+	var take func(state string, now time.Time) (string, rateLimitResult)
+	var ttl time.Duration
+	switch config.Algorithm {
+	case "", RateLimitSlidingWindow:
+		ttl = 2 * config.Window
+		take = func(state string, now time.Time) (string, rateLimitResult) {
+			return takeSlidingWindow(state, now, config.Limit, config.Window)
+		}
+	case RateLimitTokenBucket:
+		ttl = time.Duration(float64(config.Window) * float64(config.Burst) / float64(config.Limit))
+		take = func(state string, now time.Time) (string, rateLimitResult) {
+			return takeTokenBucket(state, now, config.Limit, config.Burst, config.Window)
+		}
+	default:
+		panic("helios: unknown rate limit algorithm " + config.Algorithm)
+	}
+
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			key := config.Key(req)
+			if key == "" {
+				f(req)
+				return
+			}
+			store := config.Store
+			if store == nil {
+				store = App.getRateLimitStore()
+			}
+			var result rateLimitResult
+			err := store.Update(config.Name+":"+key, ttl, func(state string) string {
+				var newState string
+				newState, result = take(state, time.Now())
+				return newState
+			})
+			if err != nil {
+				App.Logger().Printf("rate limit store error: %v", err)
+				if config.FailClosed {
+					req.SendError(ErrInternalServerError)
+					return
+				}
+				f(req)
+				return
+			}
+
+			req.SetHeader("X-RateLimit-Limit", strconv.Itoa(config.Limit))
+			req.SetHeader("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+			req.SetHeader("X-RateLimit-Reset", strconv.FormatInt(result.reset.Unix(), 10))
+			if !result.allowed {
+				req.SetHeader("Retry-After", strconv.Itoa(int(math.Ceil(result.retryAfter.Seconds()))))
+				req.SendError(ErrTooManyRequests)
+				return
+			}
+			f(req)
+		}
+	}
+}
+
+// takeSlidingWindow takes a request from the sliding window whose state
+// is "start:previous:current", the start of the current fixed window in
+// unix nano and the request counts of the previous and current windows
+func takeSlidingWindow(state string, now time.Time, limit int, window time.Duration) (string, rateLimitResult) {
+	start := now.Truncate(window)
+	var previous, current float64
+	if parts := strings.Split(state, ":"); len(parts) == 3 {
+		storedStart, _ := strconv.ParseInt(parts[0], 10, 64)
+		storedPrevious, _ := strconv.ParseFloat(parts[1], 64)
+		storedCurrent, _ := strconv.ParseFloat(parts[2], 64)
+		switch storedStart {
+		case start.UnixNano():
+			previous, current = storedPrevious, storedCurrent
+		case start.Add(-window).UnixNano():
+			previous = storedCurrent
+		}
+	}
+
+	elapsed := float64(now.Sub(start)) / float64(window)
+	weight := 1 - elapsed
+	count := previous*weight + current
+	result := rateLimitResult{reset: start.Add(window)}
+	if count+1 <= float64(limit) {
+		current++
+		result.allowed = true
+		result.remaining = int(math.Floor(float64(limit) - (count + 1)))
+	} else if current+1 > float64(limit) || previous == 0 {
+		result.retryAfter = start.Add(window).Sub(now)
+	} else {
+		// the previous window weighs enough less after this fraction of window
+		fraction := 1 - (float64(limit)-current-1)/previous
+		result.retryAfter = time.Duration(fraction*float64(window)) - now.Sub(start)
+	}
+	newState := fmt.Sprintf("%d:%g:%g", start.UnixNano(), previous, current)
+	return newState, result
+}
+
+// takeTokenBucket takes a token from the bucket whose state is "tokens:updated",
+// the tokens left and the time they are counted in unix nano
+func takeTokenBucket(state string, now time.Time, limit int, burst int, window time.Duration) (string, rateLimitResult) {
+	rate := float64(limit) / float64(window) // tokens per nanosecond
+	tokens := float64(burst)
+	if parts := strings.Split(state, ":"); len(parts) == 2 {
+		storedTokens, tokensErr := strconv.ParseFloat(parts[0], 64)
+		updated, updatedErr := strconv.ParseInt(parts[1], 10, 64)
+		if tokensErr == nil && updatedErr == nil {
+			elapsed := now.Sub(time.Unix(0, updated))
+			if elapsed < 0 {
+				elapsed = 0
+			}
+			tokens = math.Min(float64(burst), storedTokens+float64(elapsed)*rate)
+		}
+	}
+
+	result := rateLimitResult{}
+	if tokens >= 1 {
+		tokens--
+		result.allowed = true
+	} else {
+		result.retryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	result.remaining = int(math.Floor(tokens))
+	result.reset = now.Add(time.Duration((float64(burst) - tokens) / rate))
+	return fmt.Sprintf("%g:%d", tokens, now.UnixNano()), result
+}
@@ -0,0 +1,265 @@
+package helios
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeSlidingWindow(t *testing.T) {
+	start := time.Unix(1600000020, 0) // aligned to minute
+	window := time.Minute
+
+	state := ""
+	var result rateLimitResult
+	for i := 0; i < 3; i++ {
+		state, result = takeSlidingWindow(state, start.Add(10*time.Second), 3, window)
+		assert.True(t, result.allowed, "Request %d should be allowed", i)
+		assert.Equal(t, 2-i, result.remaining, "Unexpected remaining on request %d", i)
+	}
+	_, result = takeSlidingWindow(state, start.Add(20*time.Second), 3, window)
+	assert.False(t, result.allowed, "Request over the limit should be denied")
+	assert.Equal(t, 40*time.Second, result.retryAfter, "Should retry on the next window")
+	assert.Equal(t, start.Add(window), result.reset, "Should reset on the next window")
+
+	// 3 requests in previous window weigh 2 at 1/3 of the current window
+	_, result = takeSlidingWindow(state, start.Add(window+20*time.Second), 3, window)
+	assert.True(t, result.allowed, "Request should be allowed as previous window slides away")
+	assert.Equal(t, 0, result.remaining, "Unexpected remaining on sliding window")
+	state, _ = takeSlidingWindow(state, start.Add(window+20*time.Second), 3, window)
+	_, result = takeSlidingWindow(state, start.Add(window+20*time.Second), 3, window)
+	assert.False(t, result.allowed, "Request should be denied while previous window weighs too much")
+	assert.Equal(t, 20*time.Second, result.retryAfter, "Should retry when previous window weighs 1")
+
+	_, result = takeSlidingWindow(state, start.Add(5*window), 3, window)
+	assert.True(t, result.allowed, "Old windows should be forgotten")
+	assert.Equal(t, 2, result.remaining, "Old windows should not be counted")
+}
+
+func TestTakeTokenBucket(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	state := ""
+	var result rateLimitResult
+	for i := 0; i < 5; i++ {
+		state, result = takeTokenBucket(state, now, 1, 5, time.Second)
+		assert.True(t, result.allowed, "Request %d should be allowed by the burst", i)
+	}
+	assert.Equal(t, 0, result.remaining, "Bucket should be empty")
+	assert.Equal(t, now.Add(5*time.Second), result.reset, "Bucket should be full after refill")
+
+	_, result = takeTokenBucket(state, now.Add(500*time.Millisecond), 1, 5, time.Second)
+	assert.False(t, result.allowed, "Request should be denied on empty bucket")
+	assert.Equal(t, 500*time.Millisecond, result.retryAfter, "Should retry when a token is refilled")
+
+	state, result = takeTokenBucket(state, now.Add(2*time.Second), 1, 5, time.Second)
+	assert.True(t, result.allowed, "Request should be allowed after refill")
+	assert.Equal(t, 1, result.remaining, "Two tokens should be refilled")
+
+	_, result = takeTokenBucket(state, now.Add(time.Hour), 1, 5, time.Second)
+	assert.Equal(t, 4, result.remaining, "Bucket should not exceed the burst")
+}
+
+func TestCreateRateLimitMiddleware(t *testing.T) {
+	App.BeforeTest()
+
+	handler := CreateRateLimitMiddleware(RateLimitConfig{Limit: 2, Window: time.Hour})(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+	request := func(remoteAddr string) MockRequest {
+		req := NewMockRequest()
+		req.RemoteAddr = remoteAddr
+		handler(&req)
+		return req
+	}
+
+	for i := 0; i < 2; i++ {
+		req := request("10.0.0.1")
+		assert.Equal(t, http.StatusOK, req.StatusCode, "Request %d should be allowed", i)
+		assert.Equal(t, "2", req.ResponseHeader["X-RateLimit-Limit"], "Unexpected limit header")
+		assert.Equal(t, strconv.Itoa(1-i), req.ResponseHeader["X-RateLimit-Remaining"], "Unexpected remaining header")
+		assert.NotEmpty(t, req.ResponseHeader["X-RateLimit-Reset"], "Reset header should be sent")
+	}
+	req := request("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, req.StatusCode, "Request over the limit should be denied")
+	assert.Equal(t, `{"code":"too_many_requests","message":"Too many requests, try again later"}`, string(req.JSONResponse), "Unexpected response")
+	assert.NotEmpty(t, req.ResponseHeader["Retry-After"], "Retry-After header should be sent")
+	assert.Equal(t, "0", req.ResponseHeader["X-RateLimit-Remaining"], "Unexpected remaining header")
+
+	req = request("10.0.0.2")
+	assert.Equal(t, http.StatusOK, req.StatusCode, "Other ip should have its own limit")
+
+	otherHandler := CreateRateLimitMiddleware(RateLimitConfig{Limit: 1, Window: time.Hour})(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+	otherReq := NewMockRequest()
+	otherReq.RemoteAddr = "10.0.0.1"
+	otherHandler(&otherReq)
+	assert.Equal(t, http.StatusOK, otherReq.StatusCode, "Other route should have its own limit")
+
+	assert.Panics(t, func() {
+		CreateRateLimitMiddleware(RateLimitConfig{Limit: 1})
+	}, "Limit without window should panic")
+	assert.Panics(t, func() {
+		CreateRateLimitMiddleware(RateLimitConfig{Limit: 1, Window: time.Second, Algorithm: "leaky"})
+	}, "Unknown algorithm should panic")
+}
+
+func TestRateLimitKey(t *testing.T) {
+	App.BeforeTest()
+
+	handler := CreateRateLimitMiddleware(RateLimitConfig{
+		Limit:     1,
+		Window:    time.Hour,
+		Algorithm: RateLimitTokenBucket,
+		Key:       RateLimitByUser,
+		Store:     NewMemoryRateLimitStore(),
+	})(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+
+	type rateLimitKeyTestCase struct {
+		user         *User
+		remoteAddr   string
+		expectedCode int
+	}
+	testCases := []rateLimitKeyTestCase{
+		{user: &User{ID: 1, IsActive: true}, remoteAddr: "10.0.0.1", expectedCode: http.StatusOK},
+		{user: &User{ID: 1, IsActive: true}, remoteAddr: "10.0.0.2", expectedCode: http.StatusTooManyRequests},
+		{user: &User{ID: 2, IsActive: true}, remoteAddr: "10.0.0.1", expectedCode: http.StatusOK},
+		{remoteAddr: "10.0.0.1", expectedCode: http.StatusOK},
+		{remoteAddr: "10.0.0.1", expectedCode: http.StatusTooManyRequests},
+	}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		req.RemoteAddr = testCase.remoteAddr
+		req.RequestHeader["x-forwarded-for"] = fmt.Sprintf("192.168.0.%d", i)
+		if testCase.user != nil {
+			req.SetUser(testCase.user)
+		}
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+	}
+
+	assert.Nil(t, App.SetTrustedProxies("10.0.0.0/8"), "Failed to set trusted proxies")
+	defer App.SetTrustedProxies() // nolint:errcheck
+	type rateLimitProxyTestCase struct {
+		forwardedFor string
+		expectedCode int
+	}
+	proxyTestCases := []rateLimitProxyTestCase{
+		{forwardedFor: "1.1.1.1", expectedCode: http.StatusOK},
+		{forwardedFor: "2.2.2.2, 1.1.1.1", expectedCode: http.StatusTooManyRequests},
+		{forwardedFor: "1.1.1.1, 10.0.0.3", expectedCode: http.StatusTooManyRequests},
+		{forwardedFor: "1.1.1.2", expectedCode: http.StatusOK},
+	}
+	for i, testCase := range proxyTestCases {
+		req := NewMockRequest()
+		req.RemoteAddr = "10.0.0.9"
+		req.RequestHeader["x-forwarded-for"] = testCase.forwardedFor
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code behind proxy on test case %d", i)
+	}
+
+	unlimited := CreateRateLimitMiddleware(RateLimitConfig{Limit: 1, Window: time.Hour, Key: func(req Request) string {
+		return req.GetHeader("X-Api-Key")
+	}})(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+	for i := 0; i < 3; i++ {
+		req := NewMockRequest()
+		unlimited(&req)
+		assert.Equal(t, http.StatusOK, req.StatusCode, "Request with empty key should not be limited")
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	var received []string
+	update := func(ttl time.Duration) {
+		store.Update("key", ttl, func(state string) string {
+			received = append(received, state)
+			return state + "x"
+		})
+	}
+	update(time.Hour)
+	update(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	update(time.Hour)
+	assert.Equal(t, []string{"", "x", ""}, received, "Expired state should be empty")
+}
+
+func TestDBRateLimitStore(t *testing.T) {
+	App.BeforeTest()
+	DB.AutoMigrate(RateLimitModel{})
+	DB.Delete(RateLimitModel{})
+
+	handler := CreateRateLimitMiddleware(RateLimitConfig{
+		Name:   "login",
+		Limit:  1,
+		Window: time.Hour,
+		Store:  NewDBRateLimitStore(),
+	})(func(req Request) {
+		req.SendJSON("ok", http.StatusOK)
+	})
+	expected := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, code := range expected {
+		req := NewMockRequest()
+		req.RemoteAddr = "10.0.0.1"
+		handler(&req)
+		assert.Equal(t, code, req.StatusCode, "Unexpected status code on request %d", i)
+	}
+
+	var stored RateLimitModel
+	DB.Where("limit_key = ?", "login:ip:10.0.0.1").First(&stored)
+	assert.NotEmpty(t, stored.State, "State should be stored in database")
+
+	DB.Create(&RateLimitModel{Key: "old", State: "1:0:1", ExpiresAt: time.Now().Add(-time.Hour)})
+	req := NewMockRequest()
+	req.RemoteAddr = "10.0.0.2"
+	handler(&req)
+	var count int
+	DB.Model(RateLimitModel{}).Where("limit_key = ?", "old").Count(&count)
+	assert.Equal(t, 0, count, "Expired state should be removed")
+}
+
+type rateLimitTestFailingStore struct{}
+
+func (rateLimitTestFailingStore) Update(key string, ttl time.Duration, fn func(state string) string) error {
+	return errors.New("store is down")
+}
+
+func TestRateLimitStoreError(t *testing.T) {
+	App.BeforeTest()
+	App.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer App.SetLogger(nil)
+
+	type rateLimitStoreErrorTestCase struct {
+		failClosed   bool
+		expectedCode int
+	}
+	testCases := []rateLimitStoreErrorTestCase{
+		{failClosed: false, expectedCode: http.StatusOK},
+		{failClosed: true, expectedCode: http.StatusInternalServerError},
+	}
+	for i, testCase := range testCases {
+		handler := CreateRateLimitMiddleware(RateLimitConfig{
+			Limit:      1,
+			Window:     time.Hour,
+			Store:      rateLimitTestFailingStore{},
+			FailClosed: testCase.failClosed,
+		})(func(req Request) {
+			req.SendJSON("ok", http.StatusOK)
+		})
+		req := NewMockRequest()
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+	}
+}
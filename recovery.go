@@ -0,0 +1,55 @@
+package helios
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+)
+
+// RecoveryMiddleware recovers panic in the handler, logs it with the stack
+// trace, and responds with ErrInternalServerError. In debug mode, the panic
+// message and the stack trace are included in the response. It is used by
+// Handle, so every handler served by Helios is already recovered.
+func RecoveryMiddleware(f HTTPHandler) HTTPHandler {
+	return func(req Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// let net/http abort the response silently
+				panic(recovered)
+			}
+
+			stack := debug.Stack()
+			App.Logger().Printf("panic serving %s %s from %s: %v\n%s", req.GetMethod(), req.GetPath(), req.ClientIP(), recovered, stack)
+
+			message := ErrInternalServerError.GetMessage()
+			if App.IsDebug() {
+				message["panic"] = fmt.Sprint(recovered)
+				message["stack"] = string(stack)
+			}
+			req.SendJSON(message, ErrInternalServerError.GetStatusCode())
+		}()
+		f(req)
+	}
+}
+
+var defaultLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+// SetLogger sets the logger used to report errors while serving request,
+// the default logs to os.Stderr
+func (app *Helios) SetLogger(logger *log.Logger) {
+	app.logger = logger
+}
+
+// Logger returns the logger used to report errors while serving request
+func (app *Helios) Logger() *log.Logger {
+	if app.logger == nil {
+		return defaultLogger
+	}
+	return app.logger
+}
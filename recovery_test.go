@@ -0,0 +1,50 @@
+package helios
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	App.BeforeTest()
+
+	var logs bytes.Buffer
+	App.SetLogger(log.New(&logs, "", 0))
+	defer App.SetLogger(nil)
+
+	f := func(req Request) {
+		panic("something went wrong")
+	}
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/orders", nil)
+	Handle(f)(recorder, request)
+
+	var response map[string]interface{}
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "Panic should respond with internal server error")
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response), "Response should be json")
+	assert.Equal(t, ErrInternalServerError.Code, response["code"], "Different error code")
+	assert.NotContains(t, response, "panic", "Panic message should be hidden outside debug mode")
+	assert.Contains(t, logs.String(), "POST /orders", "Request should be logged")
+	assert.Contains(t, logs.String(), "something went wrong", "Panic message should be logged")
+	assert.Contains(t, logs.String(), "recovery_test.go", "Stack trace should be logged")
+
+	App.SetDebug(true)
+	defer App.SetDebug(false)
+	req := NewMockRequest()
+	RecoveryMiddleware(f)(&req)
+	response = nil
+	assert.Equal(t, http.StatusInternalServerError, req.StatusCode, "Panic should respond with internal server error")
+	assert.Nil(t, json.Unmarshal(req.JSONResponse, &response), "Response should be json")
+	assert.Equal(t, "something went wrong", response["panic"], "Panic message should be shown in debug mode")
+	assert.Contains(t, response["stack"], "recovery_test.go", "Stack trace should be shown in debug mode")
+
+	assert.Panics(t, func() {
+		RecoveryMiddleware(func(req Request) { panic(http.ErrAbortHandler) })(&req)
+	}, "ErrAbortHandler should not be recovered")
+}
@@ -0,0 +1,366 @@
+package helios
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// URLParamExtractor extracts the url params from http request,
+// so they can be retrieved by Request.GetURLParam. It is used when
+// the request is not routed by Helios router.
+type URLParamExtractor interface {
+	ExtractURLParams(r *http.Request) map[string]string
+}
+
+// URLParamExtractorFunc is an adapter to use ordinary function as URLParamExtractor.
+// For example, gorilla/mux can be used with:
+//     helios.App.SetURLParamExtractor(helios.URLParamExtractorFunc(mux.Vars))
+// and chi with:
+//     helios.App.SetURLParamExtractor(helios.URLParamExtractorFunc(func(r *http.Request) map[string]string {
+//         return map[string]string{"id": chi.URLParam(r, "id")}
+//     }))
+type URLParamExtractorFunc func(r *http.Request) map[string]string
+
+// ExtractURLParams calls f(r)
+func (f URLParamExtractorFunc) ExtractURLParams(r *http.Request) map[string]string {
+	return f(r)
+}
+
+type contextKey string
+
+const urlParamsContextKey contextKey = "helios-url-params"
+
+// Router dispatches http request to the HTTPHandler registered
+// with matching method and pattern. Pattern consists of segments
+// separated by slash, where a segment can be a param written as {name}.
+// For example, /users/{id} matches /users/12 with id param "12".
+// The param can be checked by a converter written as {name:converter},
+// e.g. /users/{id:int} doesn't match /users/abc, see RegisterURLParamConverter.
+// Static segment has higher priority than param segment with converter,
+// which has higher priority than param segment without converter, so
+// /users/me is matched before /users/{id} regardless of the order.
+type Router struct {
+	root        *Router
+	prefix      string
+	middlewares []Middleware
+	routes      []*route
+	converters  map[string]URLParamConverter
+}
+
+type route struct {
+	method   string
+	pattern  string
+	segments []routeSegment
+	handler  func(http.ResponseWriter, *http.Request)
+	// optionsHandler answers OPTIONS request to the pattern with the route
+	// middlewares, so CORS middleware can answer the preflight request
+	optionsHandler func(http.ResponseWriter, *http.Request)
+}
+
+type routeSegment struct {
+	value     string
+	isParam   bool
+	converter URLParamConverter
+}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method  string
+	Pattern string
+}
+
+// NewRouter returns new empty Router
+func NewRouter() *Router {
+	router := &Router{}
+	router.root = router
+	return router
+}
+
+// Group returns new Router that shares the routes with router.
+// Every route registered to the group is prefixed by prefix
+// and wrapped by the group middlewares after router middlewares.
+func (router *Router) Group(prefix string, m ...Middleware) *Router {
+	middlewares := make([]Middleware, 0, len(router.middlewares)+len(m))
+	middlewares = append(middlewares, router.middlewares...)
+	middlewares = append(middlewares, m...)
+	return &Router{
+		root:        router.root,
+		prefix:      joinPath(router.prefix, prefix),
+		middlewares: middlewares,
+	}
+}
+
+// AddRoute registers handler for the method and pattern
+func (router *Router) AddRoute(method string, pattern string, f HTTPHandler, m ...Middleware) {
+	middlewares := make([]Middleware, 0, len(router.middlewares)+len(m))
+	middlewares = append(middlewares, router.middlewares...)
+	middlewares = append(middlewares, m...)
+
+	fullPattern := joinPath(router.prefix, pattern)
+	router.root.routes = append(router.root.routes, &route{
+		method:   method,
+		pattern:  fullPattern,
+		segments: router.root.parsePattern(fullPattern),
+		handler:  WithMiddleware(f, middlewares),
+		optionsHandler: WithMiddleware(func(req Request) {
+			req.SendStatus(http.StatusNoContent)
+		}, middlewares),
+	})
+}
+
+// GET registers handler for GET request
+func (router *Router) GET(pattern string, f HTTPHandler, m ...Middleware) {
+	router.AddRoute(http.MethodGet, pattern, f, m...)
+}
+
+// POST registers handler for POST request
+func (router *Router) POST(pattern string, f HTTPHandler, m ...Middleware) {
+	router.AddRoute(http.MethodPost, pattern, f, m...)
+}
+
+// PUT registers handler for PUT request
+func (router *Router) PUT(pattern string, f HTTPHandler, m ...Middleware) {
+	router.AddRoute(http.MethodPut, pattern, f, m...)
+}
+
+// PATCH registers handler for PATCH request
+func (router *Router) PATCH(pattern string, f HTTPHandler, m ...Middleware) {
+	router.AddRoute(http.MethodPatch, pattern, f, m...)
+}
+
+// DELETE registers handler for DELETE request
+func (router *Router) DELETE(pattern string, f HTTPHandler, m ...Middleware) {
+	router.AddRoute(http.MethodDelete, pattern, f, m...)
+}
+
+// Routes returns all routes registered to the router, sorted by pattern
+func (router *Router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(router.root.routes))
+	for _, r := range router.root.routes {
+		routes = append(routes, RouteInfo{Method: r.method, Pattern: r.pattern})
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Pattern < routes[j].Pattern
+	})
+	return routes
+}
+
+// ServeHTTP dispatches the request to the matching route. If there is no
+// route with matching pattern, ErrNotFound is sent. If there is a route with
+// matching pattern but different method, ErrMethodNotAllowed is sent, except
+// for OPTIONS request which is answered with 204 and the allowed methods
+// after passing through the middlewares of the most specific route.
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	matched, params, allowed := router.root.match(r.Method, r.URL.Path)
+	if matched == nil {
+		if r.Method == http.MethodOptions && len(allowed) > 0 {
+			matched, params = router.root.matchPattern(r.URL.Path)
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+			ctx := context.WithValue(r.Context(), urlParamsContextKey, params)
+			matched.optionsHandler(w, r.WithContext(ctx))
+		} else if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			Handle(sendErrorHandler(ErrMethodNotAllowed))(w, r)
+		} else {
+			Handle(sendErrorHandler(ErrNotFound))(w, r)
+		}
+		return
+	}
+	ctx := context.WithValue(r.Context(), urlParamsContextKey, params)
+	matched.handler(w, r.WithContext(ctx))
+}
+
+// match returns the most specific route that matches method and path.
+// If no route matches, it returns the methods of routes that match the path.
+func (router *Router) match(method string, path string) (*route, map[string]string, []string) {
+	pathSegments := splitPath(path)
+	var best *route
+	var bestParams map[string]string
+	allowed := make([]string, 0)
+	for _, r := range router.routes {
+		params, ok := r.matchPath(pathSegments)
+		if !ok {
+			continue
+		}
+		if r.method != method && !(method == http.MethodHead && r.method == http.MethodGet) {
+			allowed = appendUnique(allowed, r.method)
+			continue
+		}
+		if best == nil || r.moreSpecificThan(best) {
+			best = r
+			bestParams = params
+		}
+	}
+	return best, bestParams, allowed
+}
+
+// matchPattern returns the most specific route that matches path regardless of the method
+func (router *Router) matchPattern(path string) (*route, map[string]string) {
+	pathSegments := splitPath(path)
+	var best *route
+	var bestParams map[string]string
+	for _, r := range router.routes {
+		if params, ok := r.matchPath(pathSegments); ok && (best == nil || r.moreSpecificThan(best)) {
+			best = r
+			bestParams = params
+		}
+	}
+	return best, bestParams
+}
+
+func (r *route) matchPath(pathSegments []string) (map[string]string, bool) {
+	if len(pathSegments) != len(r.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range r.segments {
+		if segment.isParam {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			if segment.converter != nil && segment.converter(pathSegments[i]) != nil {
+				return nil, false
+			}
+			params[segment.value] = pathSegments[i]
+		} else if segment.value != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// moreSpecificThan returns true if the first segment that differs
+// in specificity between r and other is more specific on r
+func (r *route) moreSpecificThan(other *route) bool {
+	for i := range r.segments {
+		if r.segments[i].specificity() != other.segments[i].specificity() {
+			return r.segments[i].specificity() > other.segments[i].specificity()
+		}
+	}
+	return false
+}
+
+func (segment routeSegment) specificity() int {
+	if !segment.isParam {
+		return 2
+	}
+	if segment.converter != nil {
+		return 1
+	}
+	return 0
+}
+
+// parsePattern splits pattern into segments, and panics
+// if the pattern uses converter that is not registered
+func (router *Router) parsePattern(pattern string) []routeSegment {
+	segments := make([]routeSegment, 0)
+	for _, s := range splitPath(pattern) {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			segment := routeSegment{value: s[1 : len(s)-1], isParam: true}
+			if i := strings.Index(segment.value, ":"); i >= 0 {
+				name := segment.value[i+1:]
+				segment.value = segment.value[:i]
+				segment.converter = router.urlParamConverter(name)
+				if segment.converter == nil {
+					panic("helios: unknown url param converter " + name + " in " + pattern)
+				}
+			}
+			segments = append(segments, segment)
+		} else {
+			segments = append(segments, routeSegment{value: s})
+		}
+	}
+	return segments
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func joinPath(prefix string, path string) string {
+	return "/" + strings.Trim(strings.TrimRight(prefix, "/")+"/"+strings.TrimLeft(path, "/"), "/")
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func sendErrorHandler(err Error) HTTPHandler {
+	return func(req Request) {
+		req.SendError(err)
+	}
+}
+
+// SetURLParamExtractor sets the extractor used to retrieve url params
+// of request that is not routed by Helios router
+func (app *Helios) SetURLParamExtractor(extractor URLParamExtractor) {
+	app.urlParamExtractor = extractor
+}
+
+func (app *Helios) extractURLParams(r *http.Request) map[string]string {
+	if params, ok := r.Context().Value(urlParamsContextKey).(map[string]string); ok {
+		return params
+	}
+	if app.urlParamExtractor != nil {
+		if params := app.urlParamExtractor.ExtractURLParams(r); params != nil {
+			return params
+		}
+	}
+	return make(map[string]string)
+}
+
+// Router returns the router of the app
+func (app *Helios) Router() *Router {
+	if app.router == nil {
+		app.router = NewRouter()
+	}
+	return app.router
+}
+
+// Group returns new route group of app router, see Router.Group
+func (app *Helios) Group(prefix string, m ...Middleware) *Router {
+	return app.Router().Group(prefix, m...)
+}
+
+// GET registers handler for GET request to app router
+func (app *Helios) GET(pattern string, f HTTPHandler, m ...Middleware) {
+	app.Router().GET(pattern, f, m...)
+}
+
+// POST registers handler for POST request to app router
+func (app *Helios) POST(pattern string, f HTTPHandler, m ...Middleware) {
+	app.Router().POST(pattern, f, m...)
+}
+
+// PUT registers handler for PUT request to app router
+func (app *Helios) PUT(pattern string, f HTTPHandler, m ...Middleware) {
+	app.Router().PUT(pattern, f, m...)
+}
+
+// PATCH registers handler for PATCH request to app router
+func (app *Helios) PATCH(pattern string, f HTTPHandler, m ...Middleware) {
+	app.Router().PATCH(pattern, f, m...)
+}
+
+// DELETE registers handler for DELETE request to app router
+func (app *Helios) DELETE(pattern string, f HTTPHandler, m ...Middleware) {
+	app.Router().DELETE(pattern, f, m...)
+}
+
+// ServeHTTP serves the request using app router, so the app can be used
+// directly as http.Handler:
+//     http.ListenAndServe(":8080", &helios.App)
+func (app *Helios) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	app.Router().ServeHTTP(w, r)
+}
@@ -0,0 +1,136 @@
+package helios
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	App.BeforeTest()
+
+	router := NewRouter()
+	router.GET("/users", func(req Request) {
+		req.SendJSON("list", http.StatusOK)
+	})
+	router.POST("/users", func(req Request) {
+		req.SendJSON("create", http.StatusCreated)
+	})
+	router.GET("/users/{id}", func(req Request) {
+		req.SendJSON("user "+req.GetURLParam("id"), http.StatusOK)
+	})
+	router.GET("/users/me", func(req Request) {
+		req.SendJSON("me", http.StatusOK)
+	})
+	router.DELETE("/users/{id}/posts/{postID}", func(req Request) {
+		req.SendJSON(req.GetURLParam("id")+"-"+req.GetURLParam("postID"), http.StatusOK)
+	})
+
+	type routerTestCase struct {
+		method           string
+		path             string
+		expectedCode     int
+		expectedResponse string
+	}
+	testCases := []routerTestCase{
+		{method: "GET", path: "/users", expectedCode: http.StatusOK, expectedResponse: `"list"`},
+		{method: "GET", path: "/users/", expectedCode: http.StatusOK, expectedResponse: `"list"`},
+		{method: "POST", path: "/users", expectedCode: http.StatusCreated, expectedResponse: `"create"`},
+		{method: "GET", path: "/users/12", expectedCode: http.StatusOK, expectedResponse: `"user 12"`},
+		{method: "GET", path: "/users/me", expectedCode: http.StatusOK, expectedResponse: `"me"`},
+		{method: "DELETE", path: "/users/3/posts/4", expectedCode: http.StatusOK, expectedResponse: `"3-4"`},
+		{method: "GET", path: "/posts", expectedCode: http.StatusNotFound, expectedResponse: `{"code":"not_found","message":"The requested url is not found"}`},
+		{method: "PUT", path: "/users", expectedCode: http.StatusMethodNotAllowed, expectedResponse: `{"code":"method_not_allowed","message":"The request method is not allowed for the requested url"}`},
+	}
+	for i, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(testCase.method, testCase.path, nil)
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, testCase.expectedCode, recorder.Code, "Different status code on test case %d", i)
+		assert.Equal(t, testCase.expectedResponse, recorder.Body.String(), "Different response on test case %d", i)
+	}
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("PUT", "/users", nil)
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, "GET, POST", recorder.Header().Get("Allow"), "Method not allowed should list allowed methods")
+}
+
+func TestRouterGroup(t *testing.T) {
+	App.BeforeTest()
+
+	order := make([]string, 0)
+	createMiddleware := func(name string) Middleware {
+		return func(f HTTPHandler) HTTPHandler {
+			return func(req Request) {
+				order = append(order, name)
+				f(req)
+			}
+		}
+	}
+
+	router := NewRouter()
+	api := router.Group("/api", createMiddleware("api"))
+	v1 := api.Group("v1/", createMiddleware("v1"))
+	v1.PUT("/items/{id}", func(req Request) {
+		req.SendJSON(req.GetURLParam("id"), http.StatusOK)
+	}, createMiddleware("route"))
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("PUT", "/api/v1/items/5", nil)
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "Group route should be registered to the root router")
+	assert.Equal(t, `"5"`, recorder.Body.String(), "Different response")
+	assert.Equal(t, []string{"api", "v1", "route"}, order, "Group middlewares should be executed before route middlewares")
+	assert.Equal(t, []RouteInfo{{Method: "PUT", Pattern: "/api/v1/items/{id}"}}, router.Routes(), "Different routes")
+}
+
+func TestAppRouter(t *testing.T) {
+	App.BeforeTest()
+	defer func() { App.router = nil }()
+
+	App.GET("/a", func(req Request) { req.SendJSON("a", http.StatusOK) })
+	App.POST("/a", func(req Request) { req.SendJSON("a", http.StatusCreated) })
+	App.PUT("/a", func(req Request) { req.SendJSON("a", http.StatusOK) })
+	App.PATCH("/a", func(req Request) { req.SendJSON("a", http.StatusOK) })
+	App.DELETE("/a", func(req Request) { req.SendJSON("a", http.StatusNoContent) })
+	App.Group("/b").GET("/c", func(req Request) { req.SendJSON("c", http.StatusOK) })
+
+	assert.Equal(t, 6, len(App.Router().Routes()), "Different number of routes")
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("HEAD", "/b/c", nil)
+	App.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code, "HEAD request should be served by GET handler")
+}
+
+func TestURLParamExtractor(t *testing.T) {
+	App.BeforeTest()
+	defer App.SetURLParamExtractor(nil)
+
+	request, _ := http.NewRequest("GET", "/users/3", nil)
+	req := NewHTTPRequest(httptest.NewRecorder(), request)
+	assert.Equal(t, "", req.GetURLParam("id"), "Without extractor, url param is empty")
+
+	App.SetURLParamExtractor(URLParamExtractorFunc(func(r *http.Request) map[string]string {
+		return map[string]string{"id": "3"}
+	}))
+	req = NewHTTPRequest(httptest.NewRecorder(), request)
+	assert.Equal(t, "3", req.GetURLParam("id"), "URL param should be retrieved by extractor")
+
+	App.SetURLParamExtractor(URLParamExtractorFunc(func(r *http.Request) map[string]string {
+		return nil
+	}))
+	req = NewHTTPRequest(httptest.NewRecorder(), request)
+	assert.Equal(t, "", req.GetURLParam("id"), "Nil params should be treated as empty")
+
+	var body map[string]string
+	recorder := httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/unknown", nil)
+	NewRouter().ServeHTTP(recorder, request)
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &body), "Not found response should be JSON")
+	assert.Equal(t, "not_found", body["code"], "Different error code")
+}
@@ -0,0 +1,131 @@
+package helios
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MinSecretKeyLength is the minimum length of secret key accepted outside debug mode
+const MinSecretKeyLength = 32
+
+// minSecretKeyDistinctBytes rejects keys such as "aaaa...a" or "abababab...ab"
+const minSecretKeyDistinctBytes = 8
+
+// ErrSecretKeyEmpty returned by Initialize if there is no secret key outside debug mode
+var ErrSecretKeyEmpty = errors.New("secret key is empty, set HELIOS_SECRET or call SetSecretKeys")
+
+// ErrSecretKeyWeak returned by Initialize if the secret key is too short
+// or too repetitive outside debug mode
+var ErrSecretKeyWeak = errors.New("secret key is too weak, use at least 32 random characters")
+
+// ValidateSecretKey returns error if the key is empty or weak
+func ValidateSecretKey(key []byte) error {
+	if len(key) == 0 {
+		return ErrSecretKeyEmpty
+	}
+	if len(key) < MinSecretKeyLength {
+		return ErrSecretKeyWeak
+	}
+	distinct := make(map[byte]bool)
+	for _, b := range key {
+		distinct[b] = true
+	}
+	if len(distinct) < minSecretKeyDistinctBytes {
+		return ErrSecretKeyWeak
+	}
+	return nil
+}
+
+// GenerateSecretKey returns new random secret key
+// that passes ValidateSecretKey
+func GenerateSecretKey() (string, error) {
+	b := make([]byte, 48)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SetDebug turns on or off the debug mode. In debug mode, weak secret
+// key is accepted and errors are shown in more detail.
+func (app *Helios) SetDebug(debug bool) {
+	app.debug = debug
+}
+
+// IsDebug returns true if the app is in debug mode
+func (app *Helios) IsDebug() bool {
+	return app.debug
+}
+
+// SetSecretKeys sets the keys used to sign the session cookie.
+// The first key is used to sign, and the rest are the previous keys
+// that are still accepted, so the key can be rotated without
+// invalidating existing sessions.
+func (app *Helios) SetSecretKeys(keys ...[]byte) {
+	app.secretKeys = keys
+}
+
+// SetEncryptionKey sets the key used to encrypt the session cookie,
+// so the client can't read the session content
+func (app *Helios) SetEncryptionKey(key []byte) {
+	app.encryptionKey = key
+}
+
+// loadSecretFromEnv fills the debug flag and keys that are not set from code
+// using HELIOS_DEBUG, HELIOS_SECRET, HELIOS_SECRET_FALLBACKS (comma separated),
+// and HELIOS_ENCRYPTION_KEY
+func (app *Helios) loadSecretFromEnv() {
+	if debug, err := strconv.ParseBool(os.Getenv("HELIOS_DEBUG")); err == nil && debug {
+		app.debug = true
+	}
+	if len(app.secretKeys) == 0 {
+		if secret := os.Getenv("HELIOS_SECRET"); secret != "" {
+			app.secretKeys = append(app.secretKeys, []byte(secret))
+		}
+		if fallbacks := os.Getenv("HELIOS_SECRET_FALLBACKS"); fallbacks != "" {
+			for _, fallback := range strings.Split(fallbacks, ",") {
+				if fallback = strings.TrimSpace(fallback); fallback != "" {
+					app.secretKeys = append(app.secretKeys, []byte(fallback))
+				}
+			}
+		}
+	}
+	if len(app.encryptionKey) == 0 {
+		app.encryptionKey = []byte(os.Getenv("HELIOS_ENCRYPTION_KEY"))
+	}
+}
+
+// validateSecret checks all the secret keys and encryption key. The secret key
+// is only required by the default cookie session store, used when no store is
+// set. In debug mode, the check is skipped, and random key is used if there is
+// no secret key.
+func (app *Helios) validateSecret() error {
+	if app.debug {
+		if len(app.secretKeys) == 0 {
+			key, err := GenerateSecretKey()
+			if err != nil {
+				return err
+			}
+			app.secretKeys = [][]byte{[]byte(key)}
+		}
+		return nil
+	}
+	if len(app.secretKeys) == 0 && (app.store == nil || len(app.encryptionKey) > 0) {
+		return ErrSecretKeyEmpty
+	}
+	for _, key := range app.secretKeys {
+		if err := ValidateSecretKey(key); err != nil {
+			return err
+		}
+	}
+	if len(app.encryptionKey) > 0 {
+		if err := ValidateSecretKey(app.encryptionKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
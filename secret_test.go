@@ -0,0 +1,134 @@
+package helios
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSecretKey    = "x7Kp2mQ9vR4tL8wN3zB6cF1hJ5dG0sYe"
+	testOldSecretKey = "a9Ls3kD8fJ2hG7qW1eR6tY4uI0oP5zXc"
+)
+
+func TestValidateSecretKey(t *testing.T) {
+	assert.Equal(t, ErrSecretKeyEmpty, ValidateSecretKey([]byte("")), "Empty key should be rejected")
+	assert.Equal(t, ErrSecretKeyWeak, ValidateSecretKey([]byte("secret")), "Short key should be rejected")
+	assert.Equal(t, ErrSecretKeyWeak, ValidateSecretKey([]byte("abababababababababababababababababab")), "Repetitive key should be rejected")
+	assert.Nil(t, ValidateSecretKey([]byte(testSecretKey)), "Random key should be accepted")
+
+	generated, err := GenerateSecretKey()
+	assert.Nil(t, err, "Failed to generate secret key")
+	assert.Nil(t, ValidateSecretKey([]byte(generated)), "Generated key should be valid")
+}
+
+func TestInitializeSecret(t *testing.T) {
+	os.Setenv("HELIOS_DB_URL", ":memory:")
+	defer os.Unsetenv("HELIOS_DB_URL")
+	defer func() {
+		App.SetDebug(false)
+		App.SetSecretKeys()
+		App.SetEncryptionKey(nil)
+		App.SetSessionStore(nil)
+		App.CloseDB()
+	}()
+
+	App.SetSessionStore(nil)
+	App.SetSecretKeys()
+	assert.Equal(t, ErrSecretKeyEmpty, App.Initialize(), "Initialize should refuse empty key")
+
+	App.SetSessionStore(NewMemorySessionStore())
+	assert.Nil(t, App.Initialize(), "Initialize should accept empty key without cookie store")
+	App.SetEncryptionKey([]byte(testSecretKey))
+	assert.Equal(t, ErrSecretKeyEmpty, App.Initialize(), "Initialize should refuse empty key with encryption key")
+	App.SetEncryptionKey(nil)
+	App.SetSecretKeys([]byte("weak"))
+	assert.Equal(t, ErrSecretKeyWeak, App.Initialize(), "Initialize should refuse weak key without cookie store")
+	App.SetSessionStore(nil)
+	App.CloseDB()
+
+	App.SetSecretKeys([]byte("weak"))
+	assert.Equal(t, ErrSecretKeyWeak, App.Initialize(), "Initialize should refuse weak key")
+
+	App.SetSecretKeys([]byte(testSecretKey), []byte("weak"))
+	assert.Equal(t, ErrSecretKeyWeak, App.Initialize(), "Initialize should refuse weak previous key")
+
+	App.SetSecretKeys([]byte(testSecretKey))
+	App.SetEncryptionKey([]byte("weak"))
+	assert.Equal(t, ErrSecretKeyWeak, App.Initialize(), "Initialize should refuse weak encryption key")
+
+	App.SetDebug(true)
+	App.SetSecretKeys()
+	assert.Nil(t, App.Initialize(), "Debug mode should accept empty key")
+	assert.NotEmpty(t, App.secretKeys, "Debug mode should generate random key")
+	App.SetSessionStore(nil)
+	App.CloseDB()
+
+	App.SetDebug(false)
+	App.SetSecretKeys()
+	App.SetEncryptionKey(nil)
+	os.Setenv("HELIOS_SECRET", testSecretKey)
+	os.Setenv("HELIOS_SECRET_FALLBACKS", testOldSecretKey+", ")
+	os.Setenv("HELIOS_ENCRYPTION_KEY", testOldSecretKey)
+	defer os.Unsetenv("HELIOS_SECRET")
+	defer os.Unsetenv("HELIOS_SECRET_FALLBACKS")
+	defer os.Unsetenv("HELIOS_ENCRYPTION_KEY")
+	assert.Nil(t, App.Initialize(), "Initialize should accept strong keys from env")
+	assert.Equal(t, [][]byte{[]byte(testSecretKey), []byte(testOldSecretKey)}, App.secretKeys, "Different keys from env")
+	assert.NotNil(t, App.store.(*CookieSessionStore).aead, "Encryption key should be applied to cookie store")
+}
+
+func TestCookieSessionStoreKeyRotation(t *testing.T) {
+	oldStore := NewCookieSessionStore([]byte(testOldSecretKey))
+	newStore := NewCookieSessionStore([]byte(testSecretKey), []byte(testOldSecretKey))
+
+	request, _ := http.NewRequest("GET", "/", nil)
+	session := NewSession()
+	session.Values["user"] = "abc"
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, oldStore.Save(recorder, request, session), "Failed to save session")
+
+	loaded, err := newStore.Get(requestWithCookies(recorder))
+	assert.Nil(t, err, "Session signed with previous key should be accepted")
+	assert.Equal(t, "abc", loaded.Values["user"], "Different session value")
+
+	recorderNew := httptest.NewRecorder()
+	assert.Nil(t, newStore.Save(recorderNew, request, loaded), "Failed to save session")
+	_, err = oldStore.Get(requestWithCookies(recorderNew))
+	assert.Equal(t, ErrInvalidSessionCookie, err, "Session should be signed with the current key")
+
+	assert.Equal(t, ErrSecretKeyEmpty, NewCookieSessionStore().Save(httptest.NewRecorder(), request, session), "Store without key can't sign")
+}
+
+func TestCookieSessionStoreEncryption(t *testing.T) {
+	store := NewCookieSessionStore([]byte(testSecretKey))
+	assert.Nil(t, store.SetEncryptionKey([]byte(testOldSecretKey)), "Failed to set encryption key")
+
+	request, _ := http.NewRequest("GET", "/", nil)
+	session := NewSession()
+	session.Values["user"] = "confidential-value"
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, store.Save(recorder, request, session), "Failed to save session")
+
+	plainStore := NewCookieSessionStore([]byte(testSecretKey))
+	plainRecorder := httptest.NewRecorder()
+	assert.Nil(t, plainStore.Save(plainRecorder, request, session), "Failed to save session")
+	encrypted, _ := base64.RawURLEncoding.DecodeString(strings.Split(recorder.Result().Cookies()[0].Value, "|")[0])
+	plain, _ := base64.RawURLEncoding.DecodeString(strings.Split(plainRecorder.Result().Cookies()[0].Value, "|")[0])
+	assert.NotContains(t, string(encrypted), "confidential-value", "Encrypted cookie should not contain the value")
+	assert.Contains(t, string(plain), "confidential-value", "Plain cookie contains the value")
+
+	loaded, err := store.Get(requestWithCookies(recorder))
+	assert.Nil(t, err, "Failed to load encrypted session")
+	assert.Equal(t, "confidential-value", loaded.Values["user"], "Different session value")
+
+	otherStore := NewCookieSessionStore([]byte(testSecretKey))
+	assert.Nil(t, otherStore.SetEncryptionKey([]byte(testSecretKey)), "Failed to set encryption key")
+	_, err = otherStore.Get(requestWithCookies(recorder))
+	assert.Equal(t, ErrInvalidSessionCookie, err, "Session encrypted with other key should be rejected")
+}
@@ -0,0 +1,24 @@
+//go:build go1.22
+// +build go1.22
+
+package helios
+
+import "net/http"
+
+// PathValueExtractor returns URLParamExtractor that reads the wildcards
+// of http.ServeMux patterns introduced in Go 1.22, for example:
+//     mux.HandleFunc("GET /users/{id}", helios.Handle(handler))
+//     helios.App.SetURLParamExtractor(helios.PathValueExtractor("id"))
+// The main module has to declare go 1.22 or newer, otherwise
+// http.ServeMux falls back to the old pattern syntax.
+func PathValueExtractor(names ...string) URLParamExtractor {
+	return URLParamExtractorFunc(func(r *http.Request) map[string]string {
+		params := make(map[string]string)
+		for _, name := range names {
+			if value := r.PathValue(name); value != "" {
+				params[name] = value
+			}
+		}
+		return params
+	})
+}
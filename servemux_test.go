@@ -0,0 +1,33 @@
+//go:build go1.22
+// +build go1.22
+
+// The module targets older Go, so the Go 1.22 ServeMux patterns
+// have to be enabled explicitly for the test binary.
+
+//go:debug httpmuxgo121=0
+
+package helios
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathValueExtractor(t *testing.T) {
+	App.BeforeTest()
+	App.SetURLParamExtractor(PathValueExtractor("id", "slug"))
+	defer App.SetURLParamExtractor(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{id}/{slug}", Handle(func(req Request) {
+		req.SendJSON(req.GetURLParam("id")+"/"+req.GetURLParam("slug"), http.StatusOK)
+	}))
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/posts/7/hello", nil)
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, `"7/hello"`, recorder.Body.String(), "URL params should be read from ServeMux path values")
+}
@@ -0,0 +1,146 @@
+package helios
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ServerConfig is the configuration of http server started by Run
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// ShutdownTimeout is the maximum time to wait for in-flight
+	// requests and shutdown hooks before the server stops
+	ShutdownTimeout time.Duration
+}
+
+// DefaultServerConfig returns the default timeouts of http server
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+	}
+}
+
+// ErrServerNotRunning returned by Shutdown if the server is not started by Run
+var ErrServerNotRunning = errors.New("server is not running")
+
+// SetServerConfig sets the configuration of http server started by Run
+func (app *Helios) SetServerConfig(config ServerConfig) {
+	app.serverConfig = &config
+}
+
+// OnStart registers hook that is run before the server starts serving.
+// The hooks are run in order of registration. If a hook returns error,
+// the server doesn't start.
+func (app *Helios) OnStart(hook func() error) {
+	app.startHooks = append(app.startHooks, hook)
+}
+
+// OnShutdown registers hook that is run after the server stops accepting
+// request and the in-flight requests are finished. The hooks are run in order
+// of registration, and ctx is done when ShutdownTimeout is reached.
+func (app *Helios) OnShutdown(hook func(ctx context.Context) error) {
+	app.shutdownHooks = append(app.shutdownHooks, hook)
+}
+
+// Run serves the app router on addr until SIGINT or SIGTERM is received
+// or Shutdown is called. Then it waits for in-flight requests, runs the
+// shutdown hooks, and closes the database connections.
+func (app *Helios) Run(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	return app.serve(listener, signals)
+}
+
+// Shutdown stops the server started by Run, and waits until
+// Run has finished or ctx is done
+func (app *Helios) Shutdown(ctx context.Context) error {
+	app.serverMutex.Lock()
+	stop, done := app.stopServer, app.serverDone
+	app.stopServer = nil
+	app.serverMutex.Unlock()
+	if stop == nil {
+		return ErrServerNotRunning
+	}
+	close(stop)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (app *Helios) serve(listener net.Listener, signals <-chan os.Signal) error {
+	config := DefaultServerConfig()
+	if app.serverConfig != nil {
+		config = *app.serverConfig
+	}
+
+	for _, hook := range app.startHooks {
+		if err := hook(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	server := &http.Server{
+		Handler:           app,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	app.serverMutex.Lock()
+	app.stopServer, app.serverDone = stop, done
+	app.serverMutex.Unlock()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	var err error
+	select {
+	case <-signals:
+	case <-stop:
+	case err = <-serveErr:
+	}
+	app.serverMutex.Lock()
+	app.stopServer = nil
+	app.serverMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if shutdownErr := server.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	for _, hook := range app.shutdownHooks {
+		if hookErr := hook(ctx); err == nil {
+			err = hookErr
+		}
+	}
+	app.CloseDB()
+	return err
+}
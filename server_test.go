@@ -0,0 +1,115 @@
+package helios
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	App.BeforeTest()
+
+	order := make([]string, 0)
+	started := make(chan struct{})
+	app := &Helios{}
+	app.SetServerConfig(ServerConfig{ShutdownTimeout: time.Second})
+	app.GET("/slow", func(req Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		req.SendJSON("done", http.StatusOK)
+	})
+	app.OnStart(func() error {
+		order = append(order, "start1")
+		return nil
+	})
+	app.OnStart(func() error {
+		order = append(order, "start2")
+		return nil
+	})
+	app.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "shutdown1")
+		return nil
+	})
+	app.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "shutdown2")
+		return nil
+	})
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	signals := make(chan os.Signal, 1)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(listener, signals)
+	}()
+
+	responseBody := make(chan string, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responseBody <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		responseBody <- string(body)
+	}()
+
+	<-started
+	signals <- syscall.SIGTERM
+	assert.Nil(t, <-serveErr, "Server should stop gracefully")
+	assert.Equal(t, `"done"`, <-responseBody, "In-flight request should be finished")
+	assert.Equal(t, []string{"start1", "start2", "shutdown1", "shutdown2"}, order, "Hooks should be run in order")
+	assert.Nil(t, DB, "Database should be closed on shutdown")
+}
+
+func TestShutdown(t *testing.T) {
+	App.BeforeTest()
+
+	app := &Helios{}
+	assert.Equal(t, ErrServerNotRunning, app.Shutdown(context.Background()), "Shutdown without server should return error")
+
+	hookErr := errors.New("failed to stop worker")
+	app.OnShutdown(func(ctx context.Context) error {
+		return hookErr
+	})
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(listener, make(chan os.Signal))
+	}()
+
+	for {
+		app.serverMutex.Lock()
+		running := app.stopServer != nil
+		app.serverMutex.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, app.Shutdown(context.Background()), "Failed to shutdown server")
+	assert.Equal(t, hookErr, <-serveErr, "Shutdown hook error should be returned")
+}
+
+func TestServeStartHookFailure(t *testing.T) {
+	App.BeforeTest()
+
+	startErr := errors.New("failed to start worker")
+	app := &Helios{}
+	app.OnStart(func() error {
+		return startErr
+	})
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, startErr, app.serve(listener, make(chan os.Signal)), "Start hook error should stop the server from starting")
+	_, err := net.Dial("tcp", listener.Addr().String())
+	assert.NotNil(t, err, "Listener should be closed")
+	assert.NotNil(t, app.Run("bad-address"), "Bad address should return error")
+}
@@ -0,0 +1,523 @@
+package helios
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Session is the data of a client that is kept between requests
+type Session struct {
+	ID     string
+	Values map[string]interface{}
+	IsNew  bool
+
+	// previousID is the ID before the session is regenerated,
+	// so the store can remove the old one on save
+	previousID string
+}
+
+// NewSession returns new empty session
+func NewSession() *Session {
+	return &Session{
+		Values: make(map[string]interface{}),
+		IsNew:  true,
+	}
+}
+
+// Regenerate gives the session new ID while keeping the values.
+// It should be called after the privilege of the client changes,
+// e.g. on login, to prevent session fixation. CookieSessionStore
+// sessions have no ID, so the cookie issued before stays valid.
+func (session *Session) Regenerate() {
+	if session.previousID == "" {
+		session.previousID = session.ID
+	}
+	session.ID = ""
+}
+
+// SessionStore loads and persists the session of a request.
+// Session values are encoded using encoding/gob, so custom types
+// stored in the session have to be registered with gob.Register.
+type SessionStore interface {
+	// Get returns the session of the request. If the request has no
+	// session or it is invalid, new session is returned along with the error.
+	Get(r *http.Request) (*Session, error)
+
+	// Save persists the session and writes the cookie to the response
+	Save(w http.ResponseWriter, r *http.Request, session *Session) error
+
+	// Destroy removes the session and expires the cookie
+	Destroy(w http.ResponseWriter, r *http.Request, session *Session) error
+}
+
+// SessionOptions is the configuration of session cookie
+type SessionOptions struct {
+	Name     string
+	Path     string
+	Domain   string
+	MaxAge   int
+	Secure   bool
+	HTTPOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultSessionOptions returns the default options of session cookie.
+// The cookie name is read from SESSION_NAME environment variable,
+// and it falls back to helios_session.
+func DefaultSessionOptions() SessionOptions {
+	name := os.Getenv("SESSION_NAME")
+	if name == "" {
+		name = "helios_session"
+	}
+	return SessionOptions{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   86400 * 30,
+		HTTPOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (options SessionOptions) newCookie(value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     options.Name,
+		Value:    value,
+		Path:     options.Path,
+		Domain:   options.Domain,
+		MaxAge:   options.MaxAge,
+		Secure:   options.Secure,
+		HttpOnly: options.HTTPOnly,
+		SameSite: options.SameSite,
+	}
+	if options.MaxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(options.MaxAge) * time.Second)
+	}
+	return cookie
+}
+
+func (options SessionOptions) expiredCookie() *http.Cookie {
+	cookie := options.newCookie("")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(1, 0)
+	return cookie
+}
+
+func (options SessionOptions) expiry() time.Time {
+	if options.MaxAge <= 0 {
+		return time.Now().Add(24 * time.Hour)
+	}
+	return time.Now().Add(time.Duration(options.MaxAge) * time.Second)
+}
+
+// ErrInvalidSessionCookie returned by session store
+// if the session cookie is malformed or tampered
+var ErrInvalidSessionCookie = errors.New("session cookie is invalid")
+
+// ErrSessionExpired returned by session store if the session is expired
+var ErrSessionExpired = errors.New("session is expired")
+
+// ErrSessionCookieTooLarge returned by CookieSessionStore if the encoded
+// session is larger than browsers accept, so the cookie would be dropped
+var ErrSessionCookieTooLarge = errors.New("session cookie is larger than 4096 bytes")
+
+// maxSessionCookieSize is the size of cookie name and value accepted by browsers
+const maxSessionCookieSize = 4096
+
+// CookieSessionStore keeps the session values in the cookie itself.
+// The cookie is signed, so the client can read but not modify the values.
+// If encryption key is set, the values are encrypted as well. The session
+// has to fit in 4096 bytes cookie. As nothing is kept on the server, the
+// session has no ID, so Regenerate does nothing, and a cookie issued before
+// Regenerate or Destroy stays valid until MaxAge passes. Use a server side
+// store such as DBSessionStore if the session has to be revoked.
+type CookieSessionStore struct {
+	Options SessionOptions
+	keys    [][]byte
+	aead    cipher.AEAD
+}
+
+// NewCookieSessionStore returns new CookieSessionStore. The first key
+// is used to sign the cookie, and all keys are accepted on verification,
+// so the previous keys can be kept while rotating the key.
+func NewCookieSessionStore(keys ...[]byte) *CookieSessionStore {
+	return &CookieSessionStore{
+		Options: DefaultSessionOptions(),
+		keys:    keys,
+	}
+}
+
+// SetEncryptionKey makes the store encrypt the session values with
+// AES-256-GCM. The AES key is derived from key using SHA-256.
+func (store *CookieSessionStore) SetEncryptionKey(key []byte) error {
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	store.aead = aead
+	return nil
+}
+
+// Get decodes the session from the cookie
+func (store *CookieSessionStore) Get(r *http.Request) (*Session, error) {
+	session := NewSession()
+	cookie, err := r.Cookie(store.Options.Name)
+	if err != nil {
+		return session, nil
+	}
+	data, err := store.decode(cookie.Value)
+	if err != nil {
+		return session, err
+	}
+	values, err := decodeSessionValues(data)
+	if err != nil {
+		return session, err
+	}
+	session.Values = values
+	session.IsNew = false
+	return session, nil
+}
+
+// Save encodes the session into the cookie
+func (store *CookieSessionStore) Save(w http.ResponseWriter, r *http.Request, session *Session) error {
+	data, err := encodeSessionValues(session.Values)
+	if err != nil {
+		return err
+	}
+	value, err := store.encode(data)
+	if err != nil {
+		return err
+	}
+	if len(store.Options.Name)+len(value) > maxSessionCookieSize {
+		return ErrSessionCookieTooLarge
+	}
+	http.SetCookie(w, store.Options.newCookie(value))
+	session.IsNew = false
+	session.previousID = ""
+	return nil
+}
+
+// Destroy expires the cookie and clears the values
+func (store *CookieSessionStore) Destroy(w http.ResponseWriter, r *http.Request, session *Session) error {
+	session.Values = make(map[string]interface{})
+	http.SetCookie(w, store.Options.expiredCookie())
+	return nil
+}
+
+// encode returns base64(timestamp|data)|base64(mac), where data
+// is nonce|ciphertext if the store has encryption key
+func (store *CookieSessionStore) encode(data []byte) (string, error) {
+	if len(store.keys) == 0 {
+		return "", ErrSecretKeyEmpty
+	}
+	if store.aead != nil {
+		nonce := make([]byte, store.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		data = store.aead.Seal(nonce, nonce, data, []byte(store.Options.Name))
+	}
+	payload := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Unix()))
+	payload = append(payload, data...)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	mac := signCookie(store.keys[0], store.Options.Name, encodedPayload)
+	return encodedPayload + "|" + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+func (store *CookieSessionStore) decode(value string) ([]byte, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 2 {
+		return nil, ErrInvalidSessionCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidSessionCookie
+	}
+	verified := false
+	for _, key := range store.keys {
+		if hmac.Equal(mac, signCookie(key, store.Options.Name, parts[0])) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSessionCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(payload) < 8 {
+		return nil, ErrInvalidSessionCookie
+	}
+	timestamp := int64(binary.BigEndian.Uint64(payload[:8]))
+	if store.Options.MaxAge > 0 && time.Now().Unix()-timestamp > int64(store.Options.MaxAge) {
+		return nil, ErrSessionExpired
+	}
+	data := payload[8:]
+	if store.aead != nil {
+		nonceSize := store.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, ErrInvalidSessionCookie
+		}
+		data, err = store.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(store.Options.Name))
+		if err != nil {
+			return nil, ErrInvalidSessionCookie
+		}
+	}
+	return data, nil
+}
+
+func signCookie(key []byte, name string, value string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name + "|" + value)) // nolint:errcheck
+	return h.Sum(nil)
+}
+
+// sessionBackend persists session values by its ID
+type sessionBackend interface {
+	load(id string) (map[string]interface{}, error)
+	save(id string, values map[string]interface{}, expiresAt time.Time) error
+	delete(id string) error
+}
+
+// serverSessionStore keeps the session values on the server,
+// and only the random session ID is sent as the cookie
+type serverSessionStore struct {
+	Options SessionOptions
+	backend sessionBackend
+}
+
+// Get loads the session with ID from the cookie
+func (store *serverSessionStore) Get(r *http.Request) (*Session, error) {
+	session := NewSession()
+	cookie, err := r.Cookie(store.Options.Name)
+	if err != nil || cookie.Value == "" {
+		return session, nil
+	}
+	values, err := store.backend.load(cookie.Value)
+	if err != nil {
+		return session, err
+	}
+	session.ID = cookie.Value
+	session.Values = values
+	session.IsNew = false
+	return session, nil
+}
+
+// Save persists the values and writes the session ID as cookie.
+// If the session is regenerated, the old session is removed.
+func (store *serverSessionStore) Save(w http.ResponseWriter, r *http.Request, session *Session) error {
+	if session.previousID != "" {
+		if err := store.backend.delete(session.previousID); err != nil {
+			return err
+		}
+		session.previousID = ""
+	}
+	if session.ID == "" {
+		id, err := generateSessionID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	if err := store.backend.save(session.ID, session.Values, store.Options.expiry()); err != nil {
+		return err
+	}
+	http.SetCookie(w, store.Options.newCookie(session.ID))
+	session.IsNew = false
+	return nil
+}
+
+// Destroy removes the session and expires the cookie
+func (store *serverSessionStore) Destroy(w http.ResponseWriter, r *http.Request, session *Session) error {
+	for _, id := range []string{session.ID, session.previousID} {
+		if id == "" {
+			continue
+		}
+		if err := store.backend.delete(id); err != nil {
+			return err
+		}
+	}
+	session.ID = ""
+	session.previousID = ""
+	session.Values = make(map[string]interface{})
+	http.SetCookie(w, store.Options.expiredCookie())
+	return nil
+}
+
+// MemorySessionStore keeps the sessions in memory. The sessions
+// are lost on restart and not shared between instances, so it
+// is meant for testing and development.
+type MemorySessionStore struct {
+	serverSessionStore
+}
+
+type memorySessionBackend struct {
+	mutex    sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// NewMemorySessionStore returns new empty MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{serverSessionStore{
+		Options: DefaultSessionOptions(),
+		backend: &memorySessionBackend{sessions: make(map[string]memorySession)},
+	}}
+}
+
+func (backend *memorySessionBackend) load(id string) (map[string]interface{}, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	stored, ok := backend.sessions[id]
+	if !ok {
+		return nil, ErrInvalidSessionCookie
+	}
+	if time.Now().After(stored.expiresAt) {
+		delete(backend.sessions, id)
+		return nil, ErrSessionExpired
+	}
+	return decodeSessionValues(stored.data)
+}
+
+func (backend *memorySessionBackend) save(id string, values map[string]interface{}, expiresAt time.Time) error {
+	data, err := encodeSessionValues(values)
+	if err != nil {
+		return err
+	}
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	backend.sessions[id] = memorySession{data: data, expiresAt: expiresAt}
+	return nil
+}
+
+func (backend *memorySessionBackend) delete(id string) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	delete(backend.sessions, id)
+	return nil
+}
+
+// SessionModel is the gorm model of session kept by DBSessionStore.
+// It has to be registered with App.RegisterModel to be migrated.
+type SessionModel struct {
+	ID        string `gorm:"primary_key;size:64"`
+	Data      []byte
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table name of SessionModel
+func (SessionModel) TableName() string {
+	return "helios_sessions"
+}
+
+// DBSessionStore keeps the sessions in the App write database
+// using SessionModel
+type DBSessionStore struct {
+	serverSessionStore
+}
+
+type dbSessionBackend struct{}
+
+// NewDBSessionStore returns new DBSessionStore
+func NewDBSessionStore() *DBSessionStore {
+	return &DBSessionStore{serverSessionStore{
+		Options: DefaultSessionOptions(),
+		backend: dbSessionBackend{},
+	}}
+}
+
+func (dbSessionBackend) load(id string) (map[string]interface{}, error) {
+	var stored SessionModel
+	if err := App.WriteDB().Where("id = ?", id).First(&stored).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrInvalidSessionCookie
+		}
+		return nil, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		App.WriteDB().Delete(&stored)
+		return nil, ErrSessionExpired
+	}
+	return decodeSessionValues(stored.Data)
+}
+
+func (dbSessionBackend) save(id string, values map[string]interface{}, expiresAt time.Time) error {
+	data, err := encodeSessionValues(values)
+	if err != nil {
+		return err
+	}
+	return App.WriteDB().Save(&SessionModel{ID: id, Data: data, ExpiresAt: expiresAt}).Error
+}
+
+func (dbSessionBackend) delete(id string) error {
+	return App.WriteDB().Where("id = ?", id).Delete(&SessionModel{}).Error
+}
+
+func generateSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func encodeSessionValues(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSessionValues(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, ErrInvalidSessionCookie
+	}
+	return values, nil
+}
+
+// SetSessionStore sets the store used to load and save session
+func (app *Helios) SetSessionStore(store SessionStore) {
+	app.store = store
+}
+
+// getSession returns the session of the request. If the session
+// can't be loaded, the request continues with new empty session,
+// and the error is logged unless the session is just expired.
+func (app *Helios) getSession(r *http.Request) *Session {
+	if app.store == nil {
+		return NewSession()
+	}
+	session, err := app.store.Get(r)
+	if err != nil && err != ErrSessionExpired {
+		app.Logger().Printf("session: failed to load session: %v", err)
+	}
+	if err != nil || session == nil {
+		return NewSession()
+	}
+	return session
+}
@@ -0,0 +1,181 @@
+package helios
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// requestWithCookies returns new request carrying the cookies set on recorder
+func requestWithCookies(recorder *httptest.ResponseRecorder) *http.Request {
+	request, _ := http.NewRequest("GET", "/", nil)
+	for _, cookie := range recorder.Result().Cookies() {
+		request.AddCookie(cookie)
+	}
+	return request
+}
+
+func TestCookieSessionStore(t *testing.T) {
+	store := NewCookieSessionStore([]byte("some-secret-key"))
+	store.Options.Name = "session"
+	store.Options.Secure = true
+	store.Options.Domain = "example.com"
+	store.Options.SameSite = http.SameSiteStrictMode
+
+	request, _ := http.NewRequest("GET", "/", nil)
+	session, err := store.Get(request)
+	assert.Nil(t, err, "Request without cookie should not return error")
+	assert.True(t, session.IsNew, "Request without cookie should have new session")
+
+	session.Values["user"] = 3
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, store.Save(recorder, request, session), "Failed to save session")
+
+	cookie := recorder.Result().Cookies()[0]
+	assert.Equal(t, "session", cookie.Name, "Different cookie name")
+	assert.Equal(t, "example.com", cookie.Domain, "Different cookie domain")
+	assert.True(t, cookie.Secure, "Cookie should be secure")
+	assert.True(t, cookie.HttpOnly, "Cookie should be http only")
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite, "Different cookie same site")
+
+	loaded, err := store.Get(requestWithCookies(recorder))
+	assert.Nil(t, err, "Failed to load session")
+	assert.False(t, loaded.IsNew, "Loaded session should not be new")
+	assert.Equal(t, 3, loaded.Values["user"], "Different session value")
+
+	tampered, _ := http.NewRequest("GET", "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: "session", Value: cookie.Value + "a"})
+	tamperedSession, err := store.Get(tampered)
+	assert.Equal(t, ErrInvalidSessionCookie, err, "Tampered cookie should be rejected")
+	assert.Empty(t, tamperedSession.Values, "Tampered cookie should give empty session")
+
+	otherStore := NewCookieSessionStore([]byte("other-secret-key"))
+	otherStore.Options.Name = "session"
+	_, err = otherStore.Get(requestWithCookies(recorder))
+	assert.Equal(t, ErrInvalidSessionCookie, err, "Cookie signed with other key should be rejected")
+
+	recorderDestroy := httptest.NewRecorder()
+	assert.Nil(t, store.Destroy(recorderDestroy, request, loaded), "Failed to destroy session")
+	assert.Empty(t, loaded.Values, "Destroyed session should be empty")
+	assert.True(t, recorderDestroy.Result().Cookies()[0].MaxAge < 0, "Destroyed session cookie should be expired")
+
+	large := NewSession()
+	large.Values["data"] = strings.Repeat("a", maxSessionCookieSize)
+	recorderLarge := httptest.NewRecorder()
+	assert.Equal(t, ErrSessionCookieTooLarge, store.Save(recorderLarge, request, large), "Too large session should be rejected")
+	assert.Empty(t, recorderLarge.Result().Cookies(), "Too large session should not set cookie")
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	store := NewMemorySessionStore()
+
+	request, _ := http.NewRequest("GET", "/", nil)
+	session, _ := store.Get(request)
+	session.Values["user"] = "abc"
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, store.Save(recorder, request, session), "Failed to save session")
+	assert.NotEmpty(t, session.ID, "Saved session should have ID")
+
+	loaded, err := store.Get(requestWithCookies(recorder))
+	assert.Nil(t, err, "Failed to load session")
+	assert.Equal(t, session.ID, loaded.ID, "Different session ID")
+	assert.Equal(t, "abc", loaded.Values["user"], "Different session value")
+
+	oldID := loaded.ID
+	loaded.Regenerate()
+	recorderRegenerate := httptest.NewRecorder()
+	assert.Nil(t, store.Save(recorderRegenerate, request, loaded), "Failed to save regenerated session")
+	assert.NotEqual(t, oldID, loaded.ID, "Regenerated session should have new ID")
+	_, errOld := store.Get(requestWithCookies(recorder))
+	assert.NotNil(t, errOld, "Old session ID should be invalid after regeneration")
+	regenerated, errNew := store.Get(requestWithCookies(recorderRegenerate))
+	assert.Nil(t, errNew, "Failed to load regenerated session")
+	assert.Equal(t, "abc", regenerated.Values["user"], "Regenerated session should keep the values")
+
+	recorderDestroy := httptest.NewRecorder()
+	assert.Nil(t, store.Destroy(recorderDestroy, request, regenerated), "Failed to destroy session")
+	_, errDestroyed := store.Get(requestWithCookies(recorderRegenerate))
+	assert.NotNil(t, errDestroyed, "Destroyed session should be invalid")
+
+	assert.Nil(t, store.backend.save("expired", map[string]interface{}{}, time.Now().Add(-time.Second)), "Failed to save session")
+	expiredRequest, _ := http.NewRequest("GET", "/", nil)
+	expiredRequest.AddCookie(&http.Cookie{Name: store.Options.Name, Value: "expired"})
+	_, errExpired := store.Get(expiredRequest)
+	assert.Equal(t, ErrSessionExpired, errExpired, "Expired session should be rejected")
+}
+
+func TestDBSessionStore(t *testing.T) {
+	App.BeforeTest()
+	DB.AutoMigrate(SessionModel{})
+
+	store := NewDBSessionStore()
+	request, _ := http.NewRequest("GET", "/", nil)
+	session, _ := store.Get(request)
+	session.Values["user"] = uint(5)
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, store.Save(recorder, request, session), "Failed to save session")
+
+	var count int
+	DB.Model(SessionModel{}).Count(&count)
+	assert.Equal(t, 1, count, "Session should be stored in database")
+
+	loaded, err := store.Get(requestWithCookies(recorder))
+	assert.Nil(t, err, "Failed to load session")
+	assert.Equal(t, uint(5), loaded.Values["user"], "Different session value")
+
+	loaded.Regenerate()
+	assert.Nil(t, store.Save(httptest.NewRecorder(), request, loaded), "Failed to save regenerated session")
+	DB.Model(SessionModel{}).Count(&count)
+	assert.Equal(t, 1, count, "Old session should be removed on regeneration")
+
+	assert.Nil(t, store.Destroy(httptest.NewRecorder(), request, loaded), "Failed to destroy session")
+	DB.Model(SessionModel{}).Count(&count)
+	assert.Equal(t, 0, count, "Session should be removed from database")
+}
+
+func TestHTTPRequestSession(t *testing.T) {
+	App.BeforeTest()
+
+	request, _ := http.NewRequest("GET", "/", nil)
+	recorder := httptest.NewRecorder()
+	req := NewHTTPRequest(recorder, request)
+	assert.Nil(t, req.GetSessionData("user"), "New session should be empty")
+	req.SetSessionData("user", 7)
+	assert.Nil(t, req.SaveSession(), "Failed to save session")
+
+	nextRecorder := httptest.NewRecorder()
+	nextReq := NewHTTPRequest(nextRecorder, requestWithCookies(recorder))
+	assert.Equal(t, 7, nextReq.GetSessionData("user"), "Session should be kept between request")
+
+	nextReq.RegenerateSession()
+	assert.Nil(t, nextReq.SaveSession(), "Failed to save regenerated session")
+	assert.NotEqual(t, recorder.Result().Cookies()[0].Value, nextRecorder.Result().Cookies()[0].Value, "Regenerated session should have new cookie")
+
+	lastReq := NewHTTPRequest(httptest.NewRecorder(), requestWithCookies(nextRecorder))
+	assert.Equal(t, 7, lastReq.GetSessionData("user"), "Regenerated session should keep the data")
+	lastReq.DestroySession()
+	assert.Nil(t, lastReq.GetSessionData("user"), "Destroyed session should be empty")
+
+	var logs bytes.Buffer
+	App.SetLogger(log.New(&logs, "", 0))
+	defer App.SetLogger(nil)
+	tampered, _ := http.NewRequest("GET", "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: DefaultSessionOptions().Name, Value: "unknown"})
+	tamperedReq := NewHTTPRequest(httptest.NewRecorder(), tampered)
+	assert.Nil(t, tamperedReq.GetSessionData("user"), "Session that can't be loaded should be empty")
+	assert.Contains(t, logs.String(), ErrInvalidSessionCookie.Error(), "Error of loading session should be logged")
+
+	mockReq := NewMockRequest()
+	mockReq.SetSessionData("user", 7)
+	mockReq.RegenerateSession()
+	assert.True(t, mockReq.SessionRegenerated, "Mock session should be marked as regenerated")
+	mockReq.DestroySession()
+	assert.True(t, mockReq.SessionDestroyed, "Mock session should be marked as destroyed")
+	assert.Nil(t, mockReq.GetSessionData("user"), "Destroyed mock session should be empty")
+}
@@ -0,0 +1,213 @@
+package helios
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// APIToken is the gorm model of token used by clients that can't keep the
+// session cookie, e.g. mobile apps. Only the sha256 hash of the token is stored,
+// the token itself is shown once by IssueAPIToken. It has to be registered with
+// App.RegisterModel to be migrated.
+type APIToken struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"userId"`
+	Name       string     `gorm:"size:100" json:"name"`
+	Prefix     string     `gorm:"size:16" json:"prefix"`
+	Hash       string     `gorm:"unique_index;size:64;not null" json:"-"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// TableName returns the table name of APIToken
+func (APIToken) TableName() string {
+	return "helios_api_tokens"
+}
+
+// ScopeList returns the scopes of token, which are separated by space in Scopes
+func (token *APIToken) ScopeList() []string {
+	return strings.Fields(token.Scopes)
+}
+
+// HasScope returns true if token is granted the scope
+func (token *APIToken) HasScope(scope string) bool {
+	for _, s := range token.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired returns true if token has expiry and it has passed
+func (token *APIToken) IsExpired() bool {
+	return token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)
+}
+
+const apiTokenPrefix = "hel_"
+
+const apiTokenContextKey = "helios-api-token"
+
+// ErrAPITokenNotFound returned by RevokeAPIToken if there is no token with the id
+var ErrAPITokenNotFound = errors.New("api token is not found")
+
+// IssueAPIToken creates token of user with the scopes, expiring after ttl,
+// or never if ttl is zero. The returned string is the token to be sent as
+// Authorization: Bearer header, it can't be retrieved again.
+func IssueAPIToken(db *gorm.DB, user *User, name string, scopes []string, ttl time.Duration) (string, *APIToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	plain := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	token := &APIToken{
+		UserID: user.ID,
+		Name:   name,
+		Prefix: plain[:len(apiTokenPrefix)+8],
+		Hash:   hashAPIToken(plain),
+		Scopes: strings.Join(scopes, " "),
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := db.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return plain, token, nil
+}
+
+// RevokeAPIToken deletes the token with the id, so it can't be used anymore
+func RevokeAPIToken(db *gorm.DB, id uint) error {
+	result := db.Where("id = ?", id).Delete(&APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+func hashAPIToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token in Authorization: Bearer header, or empty string
+func bearerToken(req Request) string {
+	authorization := req.GetHeader("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(authorization[7:])
+}
+
+// ErrInvalidToken returned when the bearer token doesn't exist,
+// is expired, or belongs to inactive user
+var ErrInvalidToken = ErrorAPI{
+	StatusCode: http.StatusUnauthorized,
+	Code:       "invalid_token",
+	Message:    "Token is invalid or expired",
+}
+
+// ErrInsufficientScope returned when the bearer token
+// doesn't have the scope required by the route
+var ErrInsufficientScope = ErrorAPI{
+	StatusCode: http.StatusForbidden,
+	Code:       "insufficient_scope",
+	Message:    "Token doesn't have the required scope",
+}
+
+// TokenAuthBackend identifies the user by APIToken sent as Authorization: Bearer
+// header. The token is put in context data, retrievable by CurrentAPIToken.
+type TokenAuthBackend struct{}
+
+// Authenticate returns the owner of the bearer token, or ErrInvalidToken
+func (TokenAuthBackend) Authenticate(req Request) (*User, error) {
+	plain := bearerToken(req)
+	if plain == "" {
+		return nil, nil
+	}
+	var token APIToken
+	if err := req.DB().Where("hash = ?", hashAPIToken(plain)).First(&token).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if token.IsExpired() {
+		return nil, ErrInvalidToken
+	}
+	var user User
+	if err := req.DB().Where("id = ?", token.UserID).First(&user).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if err := req.DB().Model(&token).UpdateColumn("last_used_at", &now).Error; err != nil {
+		return nil, err
+	}
+	token.LastUsedAt = &now
+	req.SetContextData(apiTokenContextKey, &token)
+	return &user, nil
+}
+
+// CurrentAPIToken returns the token the request is authenticated with,
+// or nil if it is not authenticated by TokenAuthBackend
+func CurrentAPIToken(req Request) *APIToken {
+	token, _ := req.GetContextData(apiTokenContextKey).(*APIToken)
+	return token
+}
+
+// CreateTokenAuthMiddleware returns middleware that requires valid bearer token
+// with all of the scopes. The owner of the token is loaded into context data,
+// retrievable by CurrentUser, and the token by CurrentAPIToken. It sends
+// ErrUnauthorized or ErrInvalidToken (401), or ErrInsufficientScope (403),
+// with WWW-Authenticate header.
+//     helios.App.POST("/orders", createOrder, helios.CreateTokenAuthMiddleware("orders:write"))
+func CreateTokenAuthMiddleware(scopes ...string) Middleware {
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			if bearerToken(req) == "" {
+				req.SetHeader("WWW-Authenticate", "Bearer")
+				req.SendError(ErrUnauthorized)
+				return
+			}
+			user, err := TokenAuthBackend{}.Authenticate(req)
+			if err != nil {
+				if errors.Is(err, ErrInvalidToken) {
+					req.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+				req.SendError(ToError(err))
+				return
+			}
+			token := CurrentAPIToken(req)
+			for _, scope := range scopes {
+				if !token.HasScope(scope) {
+					req.SetHeader("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+					req.SendError(ErrInsufficientScope)
+					return
+				}
+			}
+			req.SetContextData(userContextKey, user)
+			f(req)
+		}
+	}
+}
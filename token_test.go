@@ -0,0 +1,169 @@
+package helios
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTokenTest is setupAuthTest with empty api token table
+func setupTokenTest(t *testing.T) (*User, func()) {
+	user, teardown := setupAuthTest(t)
+	DB.AutoMigrate(APIToken{})
+	DB.Delete(APIToken{})
+	return user, teardown
+}
+
+func TestIssueAPIToken(t *testing.T) {
+	user, teardown := setupTokenTest(t)
+	defer teardown()
+
+	plain, token, err := IssueAPIToken(DB, user, "mobile", []string{"read", "write"}, time.Hour)
+	assert.Nil(t, err, "Failed to issue token")
+	assert.True(t, strings.HasPrefix(plain, token.Prefix), "Token should start with its prefix")
+	assert.NotContains(t, token.Hash, plain, "Token should be stored hashed")
+	assert.Equal(t, []string{"read", "write"}, token.ScopeList(), "Unexpected scopes")
+	assert.True(t, token.HasScope("write"), "Token should have write scope")
+	assert.False(t, token.HasScope("admin"), "Token should not have admin scope")
+	assert.False(t, token.IsExpired(), "Token should not be expired")
+
+	var stored APIToken
+	DB.First(&stored, token.ID)
+	assert.Equal(t, hashAPIToken(plain), stored.Hash, "Hash of token should be stored")
+	assert.Equal(t, user.ID, stored.UserID, "Token should belong to user")
+
+	assert.Nil(t, RevokeAPIToken(DB, token.ID), "Failed to revoke token")
+	assert.Equal(t, ErrAPITokenNotFound, RevokeAPIToken(DB, token.ID), "Revoked token should not be found")
+}
+
+func TestCreateTokenAuthMiddleware(t *testing.T) {
+	user, teardown := setupTokenTest(t)
+	defer teardown()
+
+	readToken, _, _ := IssueAPIToken(DB, user, "read", []string{"read"}, 0)
+	writeToken, _, _ := IssueAPIToken(DB, user, "write", []string{"read", "write"}, time.Hour)
+	expiredToken, _, _ := IssueAPIToken(DB, user, "expired", []string{"read", "write"}, time.Nanosecond)
+	inactiveUser, _ := NewUser("bob", "bob@example.com", "secret-password")
+	DB.Create(inactiveUser)
+	DB.Model(inactiveUser).Update("is_active", false)
+	inactiveToken, _, _ := IssueAPIToken(DB, inactiveUser, "inactive", []string{"read", "write"}, 0)
+	time.Sleep(time.Millisecond)
+
+	handler := CreateTokenAuthMiddleware("write")(func(req Request) {
+		req.SendJSON(CurrentUser(req).Username+" "+CurrentAPIToken(req).Name, http.StatusOK)
+	})
+
+	type tokenAuthTestCase struct {
+		authorization           string
+		expectedCode            int
+		expectedResponse        string
+		expectedWWWAuthenticate string
+	}
+	testCases := []tokenAuthTestCase{{
+		expectedCode:            http.StatusUnauthorized,
+		expectedResponse:        `{"code":"unauthorized","message":"Authentication credentials were not provided or are invalid"}`,
+		expectedWWWAuthenticate: "Bearer",
+	}, {
+		authorization:           "Basic YWxpY2U6c2VjcmV0",
+		expectedCode:            http.StatusUnauthorized,
+		expectedResponse:        `{"code":"unauthorized","message":"Authentication credentials were not provided or are invalid"}`,
+		expectedWWWAuthenticate: "Bearer",
+	}, {
+		authorization:           "Bearer " + writeToken,
+		expectedCode:            http.StatusOK,
+		expectedResponse:        `"alice write"`,
+		expectedWWWAuthenticate: "",
+	}, {
+		authorization:           "bearer " + writeToken,
+		expectedCode:            http.StatusOK,
+		expectedResponse:        `"alice write"`,
+		expectedWWWAuthenticate: "",
+	}, {
+		authorization:           "Bearer " + readToken,
+		expectedCode:            http.StatusForbidden,
+		expectedResponse:        `{"code":"insufficient_scope","message":"Token doesn't have the required scope"}`,
+		expectedWWWAuthenticate: `Bearer error="insufficient_scope", scope="write"`,
+	}, {
+		authorization:           "Bearer " + expiredToken,
+		expectedCode:            http.StatusUnauthorized,
+		expectedResponse:        `{"code":"invalid_token","message":"Token is invalid or expired"}`,
+		expectedWWWAuthenticate: `Bearer error="invalid_token"`,
+	}, {
+		authorization:           "Bearer " + inactiveToken,
+		expectedCode:            http.StatusUnauthorized,
+		expectedResponse:        `{"code":"invalid_token","message":"Token is invalid or expired"}`,
+		expectedWWWAuthenticate: `Bearer error="invalid_token"`,
+	}, {
+		authorization:           "Bearer hel_unknown",
+		expectedCode:            http.StatusUnauthorized,
+		expectedResponse:        `{"code":"invalid_token","message":"Token is invalid or expired"}`,
+		expectedWWWAuthenticate: `Bearer error="invalid_token"`,
+	}}
+	for i, testCase := range testCases {
+		req := NewMockRequest()
+		if testCase.authorization != "" {
+			req.RequestHeader["authorization"] = testCase.authorization
+		}
+		handler(&req)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Unexpected status code on test case %d", i)
+		assert.Equal(t, testCase.expectedResponse, string(req.JSONResponse), "Unexpected response on test case %d", i)
+		assert.Equal(t, testCase.expectedWWWAuthenticate, req.ResponseHeader["WWW-Authenticate"], "Unexpected WWW-Authenticate on test case %d", i)
+	}
+
+	var used APIToken
+	DB.Where("name = ?", "write").First(&used)
+	assert.NotNil(t, used.LastUsedAt, "Last used time should be updated")
+}
+
+func TestTokenAuthBackend(t *testing.T) {
+	user, teardown := setupTokenTest(t)
+	defer teardown()
+	defer App.SetAuthBackends()
+	App.SetAuthBackends(TokenAuthBackend{}, SessionAuthBackend{})
+
+	plain, _, _ := IssueAPIToken(DB, user, "mobile", nil, 0)
+	handler := RequireAuth(func(req Request) {
+		req.SendJSON(CurrentUser(req).Username, http.StatusOK)
+	})
+
+	req := NewMockRequest()
+	req.RequestHeader["authorization"] = "Bearer " + plain
+	handler(&req)
+	assert.Equal(t, http.StatusOK, req.StatusCode, "Token should be accepted by RequireAuth")
+	assert.NotNil(t, CurrentAPIToken(&req), "Token should be put in context data")
+
+	req = NewMockRequest()
+	req.SessionData[sessionUserIDKey] = user.ID
+	handler(&req)
+	assert.Equal(t, http.StatusOK, req.StatusCode, "Session should be tried after token")
+	assert.Nil(t, CurrentAPIToken(&req), "Session request should not have token")
+}
+
+func TestTokenCommands(t *testing.T) {
+	user, teardown := setupTokenTest(t)
+	defer teardown()
+
+	var out bytes.Buffer
+	app := &Helios{}
+	app.SetOutput(&out)
+	assert.Nil(t, app.RunCommand([]string{"helios", "createtoken", "--user", "alice", "--name", "ci", "--scopes", "read,write", "--ttl", "1h"}), "Failed to run createtoken")
+	plain := strings.TrimSpace(out.String()[strings.LastIndex(out.String(), " "):])
+
+	var token APIToken
+	DB.Where("hash = ?", hashAPIToken(plain)).First(&token)
+	assert.Equal(t, user.ID, token.UserID, "Token should be issued to the user")
+	assert.Equal(t, "read write", token.Scopes, "Unexpected scopes")
+	assert.NotNil(t, token.ExpiresAt, "Token should expire")
+
+	assert.NotNil(t, app.RunCommand([]string{"helios", "createtoken", "--user", "nobody"}), "Token of unknown user should not be issued")
+
+	out.Reset()
+	id := fmt.Sprint(token.ID)
+	assert.Nil(t, app.RunCommand([]string{"helios", "revoketoken", "--id", id}), "Failed to run revoketoken")
+	assert.Equal(t, ErrAPITokenNotFound, app.RunCommand([]string{"helios", "revoketoken", "--id", id}), "Revoked token should not be found")
+}
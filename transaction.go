@@ -0,0 +1,136 @@
+package helios
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// TransactionMiddleware runs the handler inside transaction of the write
+// database, see CreateTransactionMiddleware
+var TransactionMiddleware = CreateTransactionMiddleware("")
+
+// CreateTransactionMiddleware returns middleware that opens transaction on
+// the database registered with the name, or the write database if the name
+// is empty. The transaction is available through req.DB(). It is committed
+// if the handler responds with 2xx or 3xx, and rolled back if the handler
+// responds with 4xx or 5xx or panics. The response is held until the
+// transaction is committed, so a failed commit is answered with
+// ErrInternalServerError instead, as is the database that isn't registered.
+func CreateTransactionMiddleware(name string) Middleware {
+	return func(f HTTPHandler) HTTPHandler {
+		return func(req Request) {
+			var db *gorm.DB
+			if name == "" {
+				db = req.GetWriteDB()
+			} else {
+				db = req.GetDB(name)
+			}
+			if db == nil {
+				App.Logger().Printf("transaction: database %q is not registered", name)
+				req.SendError(ErrInternalServerError)
+				return
+			}
+			tx := db.Begin()
+			if tx.Error != nil {
+				req.SendError(ErrInternalServerError)
+				return
+			}
+
+			wrapped := &transactionRequest{Request: req, name: name, tx: tx}
+			committed := false
+			defer func() {
+				if !committed {
+					tx.Rollback()
+				}
+			}()
+			f(wrapped)
+
+			if wrapped.send != nil && wrapped.code >= 400 {
+				wrapped.send(req)
+				return
+			}
+			if err := tx.Commit().Error; err != nil {
+				req.SendError(ErrInternalServerError)
+				return
+			}
+			committed = true
+			if wrapped.send != nil {
+				wrapped.send(req)
+			}
+		}
+	}
+}
+
+// transactionRequest is the Request passed to the handler by transaction
+// middleware. It holds the response until the transaction is finished.
+type transactionRequest struct {
+	Request
+	name string
+	tx   *gorm.DB
+	code int
+	send func(req Request)
+}
+
+// DB returns the transaction
+func (req *transactionRequest) DB() *gorm.DB {
+	return req.tx
+}
+
+// GetDB returns the transaction if the name is the database of the transaction
+func (req *transactionRequest) GetDB(name string) *gorm.DB {
+	if req.name != "" && name == req.name {
+		return req.tx
+	}
+	return req.Request.GetDB(name)
+}
+
+// GetReadDB returns the transaction if it is opened on the write database,
+// so the handler reads what it has written in the transaction
+func (req *transactionRequest) GetReadDB() *gorm.DB {
+	if req.onWriteDB() {
+		return req.tx
+	}
+	return req.Request.GetReadDB()
+}
+
+// GetWriteDB returns the transaction if it is opened on the write database
+func (req *transactionRequest) GetWriteDB() *gorm.DB {
+	if req.onWriteDB() {
+		return req.tx
+	}
+	return req.Request.GetWriteDB()
+}
+
+// onWriteDB returns true if the transaction is opened on the write database
+func (req *transactionRequest) onWriteDB() bool {
+	if req.name == "" {
+		return true
+	}
+	if App.dbRouter == nil {
+		return req.name == DefaultDatabase
+	}
+	return req.name == App.dbRouter.DBForWrite()
+}
+
+// Send holds the response until the transaction is finished
+func (req *transactionRequest) Send(output interface{}, code int) {
+	req.code = code
+	req.send = func(r Request) { r.Send(output, code) }
+}
+
+// SendJSON holds the response until the transaction is finished
+func (req *transactionRequest) SendJSON(output interface{}, code int) {
+	req.code = code
+	req.send = func(r Request) { r.SendJSON(output, code) }
+}
+
+// SendError holds the error response until the transaction is finished
+func (req *transactionRequest) SendError(err Error) {
+	req.code = err.GetStatusCode()
+	req.send = func(r Request) { r.SendError(err) }
+}
+
+// SendStatus holds the response until the transaction is finished
+func (req *transactionRequest) SendStatus(code int) {
+	req.code = code
+	req.send = func(r Request) { r.SendStatus(code) }
+}
@@ -0,0 +1,130 @@
+package helios
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type transactionTestModel struct {
+	ID   uint `gorm:"primary_key"`
+	Name string
+}
+
+func TestTransactionMiddleware(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&transactionTestModel{})
+	DB.AutoMigrate(&transactionTestModel{})
+
+	type transactionTestCase struct {
+		handler       HTTPHandler
+		expectedCode  int
+		expectedCount int
+	}
+	testCases := []transactionTestCase{{
+		handler: func(req Request) {
+			req.DB().Create(&transactionTestModel{Name: "a"})
+			req.SendJSON("ok", http.StatusCreated)
+		},
+		expectedCode:  http.StatusCreated,
+		expectedCount: 1,
+	}, {
+		handler: func(req Request) {
+			req.GetWriteDB().Create(&transactionTestModel{Name: "b"})
+			req.SendJSON(ErrJSONParseFailed.GetMessage(), ErrJSONParseFailed.GetStatusCode())
+		},
+		expectedCode:  http.StatusBadRequest,
+		expectedCount: 0,
+	}, {
+		handler: func(req Request) {
+			req.DB().Create(&transactionTestModel{Name: "c"})
+		},
+		expectedCode:  0,
+		expectedCount: 1,
+	}}
+	for i, testCase := range testCases {
+		DB.Delete(&transactionTestModel{})
+		req := NewMockRequest()
+		TransactionMiddleware(testCase.handler)(&req)
+
+		var count int
+		DB.Model(&transactionTestModel{}).Count(&count)
+		assert.Equal(t, testCase.expectedCode, req.StatusCode, "Different status code on test case %d", i)
+		assert.Equal(t, testCase.expectedCount, count, "Different number of rows on test case %d", i)
+	}
+}
+
+func TestTransactionMiddlewarePanic(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&transactionTestModel{})
+	DB.AutoMigrate(&transactionTestModel{})
+
+	handler := TransactionMiddleware(func(req Request) {
+		req.DB().Create(&transactionTestModel{Name: "a"})
+		panic("failed halfway")
+	})
+	req := NewMockRequest()
+	assert.Panics(t, func() { handler(&req) }, "Panic should be propagated")
+
+	var count int
+	DB.Model(&transactionTestModel{}).Count(&count)
+	assert.Equal(t, 0, count, "Transaction should be rolled back on panic")
+}
+
+func TestTransactionMiddlewareHTTP(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&transactionTestModel{})
+	DB.AutoMigrate(&transactionTestModel{})
+
+	f := func(req Request) {
+		req.DB().Create(&transactionTestModel{Name: "a"})
+		req.SendJSON("ok", http.StatusCreated)
+	}
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/", nil)
+	WithMiddleware(f, []Middleware{TransactionMiddleware})(recorder, request)
+
+	var count int
+	DB.Model(&transactionTestModel{}).Count(&count)
+	assert.Equal(t, http.StatusCreated, recorder.Code, "Different status code")
+	assert.Equal(t, "\"ok\"", recorder.Body.String(), "Response should be sent after commit")
+	assert.Equal(t, 1, count, "Transaction should be committed")
+}
+
+func TestTransactionMiddlewareReadDB(t *testing.T) {
+	App.BeforeTest()
+	DB.DropTableIfExists(&transactionTestModel{})
+	DB.AutoMigrate(&transactionTestModel{})
+	DB.DB().SetMaxOpenConns(2)
+	defer DB.DB().SetMaxOpenConns(0)
+
+	handler := TransactionMiddleware(func(req Request) {
+		req.DB().Create(&transactionTestModel{Name: "a"})
+		var count int
+		req.GetReadDB().Model(&transactionTestModel{}).Count(&count)
+		req.SendJSON(count, http.StatusOK)
+	})
+	req := NewMockRequest()
+	handler(&req)
+	assert.Equal(t, http.StatusOK, req.StatusCode, "Different status code")
+	assert.Equal(t, "1", string(req.JSONResponse), "Read database should be the transaction")
+}
+
+func TestTransactionMiddlewareUnregisteredDatabase(t *testing.T) {
+	App.BeforeTest()
+	App.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer App.SetLogger(nil)
+
+	handlerCalled := false
+	handler := CreateTransactionMiddleware("unregistered")(func(req Request) {
+		handlerCalled = true
+	})
+	req := NewMockRequest()
+	assert.NotPanics(t, func() { handler(&req) }, "Unregistered database should not panic")
+	assert.Equal(t, http.StatusInternalServerError, req.StatusCode, "Unregistered database should be internal server error")
+	assert.False(t, handlerCalled, "Handler should not be called without transaction")
+}
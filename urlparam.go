@@ -0,0 +1,185 @@
+package helios
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// URLParamConverter checks the value of url param declared as
+// {name:converter} in route pattern. The route doesn't match
+// the request if it returns error.
+type URLParamConverter func(value string) error
+
+// ErrInvalidURLParam returned by the typed url param getters,
+// e.g. GetURLParamInt64, if the param can't be parsed
+var ErrInvalidURLParam = ErrorAPI{
+	StatusCode: http.StatusBadRequest,
+	Code:       "invalid_url_param",
+	Message:    "The url param is invalid",
+}
+
+var slugPattern = regexp.MustCompile(`^[-a-zA-Z0-9_]+$`)
+
+// builtinURLParamConverters returns the converters that can be used
+// without registration: int, uint, uuid, slug, and date (2006-01-02)
+func builtinURLParamConverters() map[string]URLParamConverter {
+	return map[string]URLParamConverter{
+		"int": func(value string) error {
+			_, err := parseInt64Param(value)
+			return err
+		},
+		"uint": func(value string) error {
+			_, err := parseUint64Param(value)
+			return err
+		},
+		"uuid": func(value string) error {
+			_, err := ParseUUID(value)
+			return err
+		},
+		"slug": func(value string) error {
+			if !slugPattern.MatchString(value) {
+				return ErrInvalidURLParam
+			}
+			return nil
+		},
+		"date": func(value string) error {
+			_, err := parseTimeParam(value, "2006-01-02")
+			return err
+		},
+	}
+}
+
+// RegisterURLParamConverter adds converter that can be used in route
+// pattern as {name:converter}. Converter with the same name as built-in
+// replaces it. It has to be registered before the routes that use it.
+//     router.RegisterURLParamConverter("year", func(value string) error {
+//         if len(value) != 4 {
+//             return errors.New("year must have 4 digits")
+//         }
+//         return nil
+//     })
+//     router.GET("/archives/{year:year}", handler)
+func (router *Router) RegisterURLParamConverter(name string, converter URLParamConverter) {
+	root := router.root
+	if root.converters == nil {
+		root.converters = make(map[string]URLParamConverter)
+	}
+	root.converters[name] = converter
+}
+
+func (router *Router) urlParamConverter(name string) URLParamConverter {
+	if converter, ok := router.root.converters[name]; ok {
+		return converter
+	}
+	return builtinURLParamConverters()[name]
+}
+
+// RegisterURLParamConverter adds converter to app router,
+// see Router.RegisterURLParamConverter
+func (app *Helios) RegisterURLParamConverter(name string, converter URLParamConverter) {
+	app.Router().RegisterURLParamConverter(name, converter)
+}
+
+// UUID is 128-bit universally unique identifier
+type UUID [16]byte
+
+// ParseUUID parses UUID in the form of xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func ParseUUID(s string) (UUID, error) {
+	var uuid UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return uuid, errors.New("invalid uuid format")
+	}
+	segments := []struct{ from, to, at int }{{0, 8, 0}, {9, 13, 4}, {14, 18, 6}, {19, 23, 8}, {24, 36, 10}}
+	for _, segment := range segments {
+		if _, err := hex.Decode(uuid[segment.at:], []byte(s[segment.from:segment.to])); err != nil {
+			return uuid, errors.New("invalid uuid format")
+		}
+	}
+	return uuid, nil
+}
+
+// String returns the uuid in lowercase canonical form
+func (uuid UUID) String() string {
+	s := hex.EncodeToString(uuid[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+func parseInt64Param(value string) (int64, error) {
+	param, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidURLParam
+	}
+	return param, nil
+}
+
+func parseUint64Param(value string) (uint64, error) {
+	param, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidURLParam
+	}
+	return param, nil
+}
+
+func parseUUIDParam(value string) (UUID, error) {
+	param, err := ParseUUID(value)
+	if err != nil {
+		return param, ErrInvalidURLParam
+	}
+	return param, nil
+}
+
+func parseTimeParam(value string, layout string) (time.Time, error) {
+	param, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidURLParam
+	}
+	return param, nil
+}
+
+// GetURLParamInt64 returns the parameter of the request url as int64,
+// or ErrInvalidURLParam if it can't be parsed
+func (req *HTTPRequest) GetURLParamInt64(key string) (int64, error) {
+	return parseInt64Param(req.u[key])
+}
+
+// GetURLParamUint64 returns the parameter of the request url as uint64,
+// or ErrInvalidURLParam if it can't be parsed
+func (req *HTTPRequest) GetURLParamUint64(key string) (uint64, error) {
+	return parseUint64Param(req.u[key])
+}
+
+// GetURLParamUUID returns the parameter of the request url as UUID,
+// or ErrInvalidURLParam if it can't be parsed
+func (req *HTTPRequest) GetURLParamUUID(key string) (UUID, error) {
+	return parseUUIDParam(req.u[key])
+}
+
+// GetURLParamTime returns the parameter of the request url as time parsed
+// with layout, or ErrInvalidURLParam if it can't be parsed
+func (req *HTTPRequest) GetURLParamTime(key string, layout string) (time.Time, error) {
+	return parseTimeParam(req.u[key], layout)
+}
+
+// GetURLParamInt64 returns the url param of given key as int64
+func (req *MockRequest) GetURLParamInt64(key string) (int64, error) {
+	return parseInt64Param(req.URLParam[key])
+}
+
+// GetURLParamUint64 returns the url param of given key as uint64
+func (req *MockRequest) GetURLParamUint64(key string) (uint64, error) {
+	return parseUint64Param(req.URLParam[key])
+}
+
+// GetURLParamUUID returns the url param of given key as UUID
+func (req *MockRequest) GetURLParamUUID(key string) (UUID, error) {
+	return parseUUIDParam(req.URLParam[key])
+}
+
+// GetURLParamTime returns the url param of given key as time parsed with layout
+func (req *MockRequest) GetURLParamTime(key string, layout string) (time.Time, error) {
+	return parseTimeParam(req.URLParam[key], layout)
+}
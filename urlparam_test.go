@@ -0,0 +1,141 @@
+package helios
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLParamConverter(t *testing.T) {
+	App.BeforeTest()
+
+	router := NewRouter()
+	router.RegisterURLParamConverter("year", func(value string) error {
+		if len(value) != 4 {
+			return errors.New("year must have 4 digits")
+		}
+		return nil
+	})
+	router.GET("/users/{username}", func(req Request) {
+		req.SendJSON("username "+req.GetURLParam("username"), http.StatusOK)
+	})
+	router.GET("/users/{id:int}", func(req Request) {
+		id, _ := req.GetURLParamInt64("id")
+		req.SendJSON(id, http.StatusOK)
+	})
+	router.GET("/orders/{id:uuid}", func(req Request) {
+		id, _ := req.GetURLParamUUID("id")
+		req.SendJSON(id.String(), http.StatusOK)
+	})
+	router.GET("/posts/{slug:slug}", func(req Request) {
+		req.SendJSON(req.GetURLParam("slug"), http.StatusOK)
+	})
+	router.Group("/archives").GET("/{year:year}/{date:date}", func(req Request) {
+		date, _ := req.GetURLParamTime("date", "2006-01-02")
+		req.SendJSON(req.GetURLParam("year")+" "+date.Format("Jan 2"), http.StatusOK)
+	})
+
+	type converterTestCase struct {
+		path             string
+		expectedCode     int
+		expectedResponse string
+	}
+	testCases := []converterTestCase{
+		{path: "/users/-12", expectedCode: http.StatusOK, expectedResponse: `-12`},
+		{path: "/users/helios", expectedCode: http.StatusOK, expectedResponse: `"username helios"`},
+		{path: "/orders/3F2504E0-4F89-11D3-9A0C-0305E82C3301", expectedCode: http.StatusOK, expectedResponse: `"3f2504e0-4f89-11d3-9a0c-0305e82c3301"`},
+		{path: "/orders/12", expectedCode: http.StatusNotFound},
+		{path: "/posts/hello-world_2", expectedCode: http.StatusOK, expectedResponse: `"hello-world_2"`},
+		{path: "/posts/hello.world", expectedCode: http.StatusNotFound},
+		{path: "/archives/2020/2020-02-03", expectedCode: http.StatusOK, expectedResponse: `"2020 Feb 3"`},
+		{path: "/archives/20/2020-02-03", expectedCode: http.StatusNotFound},
+		{path: "/archives/2020/2020-02-30", expectedCode: http.StatusNotFound},
+	}
+	for i, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", testCase.path, nil)
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, testCase.expectedCode, recorder.Code, "Different status code on test case %d", i)
+		if testCase.expectedResponse != "" {
+			assert.Equal(t, testCase.expectedResponse, recorder.Body.String(), "Different response on test case %d", i)
+		}
+	}
+
+	assert.Panics(t, func() {
+		router.GET("/files/{name:unknown}", func(req Request) {})
+	}, "Unknown converter should panic")
+}
+
+func TestTypedURLParam(t *testing.T) {
+	App.BeforeTest()
+
+	req := NewMockRequest()
+	req.URLParam["int"] = "-9223372036854775808"
+	req.URLParam["uint"] = "18446744073709551615"
+	req.URLParam["uuid"] = "3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+	req.URLParam["time"] = "2020-01-02T03:04:05Z"
+	req.URLParam["bad"] = "3f2504e0_4f89_11d3_9a0c_0305e82c3301"
+
+	intParam, err := req.GetURLParamInt64("int")
+	assert.Nil(t, err, "Failed to parse int64 url param")
+	assert.Equal(t, int64(-9223372036854775808), intParam, "Different int64 url param")
+	uintParam, err := req.GetURLParamUint64("uint")
+	assert.Nil(t, err, "Failed to parse uint64 url param")
+	assert.Equal(t, uint64(18446744073709551615), uintParam, "Different uint64 url param")
+	uuidParam, err := req.GetURLParamUUID("uuid")
+	assert.Nil(t, err, "Failed to parse uuid url param")
+	assert.Equal(t, "3f2504e0-4f89-11d3-9a0c-0305e82c3301", uuidParam.String(), "Different uuid url param")
+	timeParam, err := req.GetURLParamTime("time", time.RFC3339)
+	assert.Nil(t, err, "Failed to parse time url param")
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), timeParam, "Different time url param")
+
+	_, err = req.GetURLParamInt64("bad")
+	assert.Equal(t, ErrInvalidURLParam, err, "Bad int64 url param should return error")
+	_, err = req.GetURLParamUint64("int")
+	assert.Equal(t, ErrInvalidURLParam, err, "Negative uint64 url param should return error")
+	_, err = req.GetURLParamUUID("bad")
+	assert.Equal(t, ErrInvalidURLParam, err, "Bad uuid url param should return error")
+	_, err = req.GetURLParamTime("missing", time.RFC3339)
+	assert.Equal(t, ErrInvalidURLParam, err, "Missing time url param should return error")
+	_, err = req.GetURLParamUint("uint")
+	assert.Equal(t, ErrInvalidURLParam, err, "Too large uint url param should return error")
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/orders/abc", nil)
+	Handle(HandleE(func(req Request) Error {
+		if _, err := req.GetURLParamUUID("id"); err != nil {
+			return ToError(err)
+		}
+		return nil
+	}))(recorder, request)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "Invalid url param should be bad request")
+}
+
+func TestParseUUID(t *testing.T) {
+	type parseUUIDTestCase struct {
+		value         string
+		expected      string
+		expectedValid bool
+	}
+	testCases := []parseUUIDTestCase{
+		{value: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", expected: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", expectedValid: true},
+		{value: "3F2504E0-4F89-11D3-9A0C-0305E82C3301", expected: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", expectedValid: true},
+		{value: "01234567-89ab-cdef-0123-456789ab--ef"},
+		{value: "01234567-89ab-cdef-0123-45678-9abcde"},
+		{value: "3f2504e0-4f89-11d3-9a0c-0305e82c330g"},
+		{value: "3f2504e04f8911d39a0c0305e82c3301"},
+	}
+	for i, testCase := range testCases {
+		uuid, err := ParseUUID(testCase.value)
+		if testCase.expectedValid {
+			assert.Nil(t, err, "Failed to parse uuid on test case %d", i)
+			assert.Equal(t, testCase.expected, uuid.String(), "Different uuid on test case %d", i)
+		} else {
+			assert.NotNil(t, err, "Invalid uuid should return error on test case %d", i)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package helios
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator checks value against the param of the rule, e.g. "3"
+// for min=3, and returns error whose message is shown for the field.
+// value is never nil pointer, the pointer is dereferenced before.
+type Validator func(value interface{}, param string) error
+
+// RegisterValidator adds validator that can be used in validate tag
+// with the name. Validator with the same name as built-in replaces it.
+//     App.RegisterValidator("even", func(value interface{}, param string) error {
+//         if n, ok := value.(int); ok && n%2 != 0 {
+//             return errors.New("must be even")
+//         }
+//         return nil
+//     })
+func (app *Helios) RegisterValidator(name string, validator Validator) {
+	if app.validators == nil {
+		app.validators = make(map[string]Validator)
+	}
+	app.validators[name] = validator
+
+	app.validationMutex.Lock()
+	app.validations = nil
+	app.validationMutex.Unlock()
+}
+
+// validator returns the validator with the name,
+// and whether it is the built-in one
+func (app *Helios) validator(name string) (Validator, bool) {
+	if validator, ok := app.validators[name]; ok {
+		return validator, false
+	}
+	validator, ok := builtinValidators[name]
+	return validator, ok
+}
+
+// Validate checks the fields of struct pointed by obj against the rules
+// in their validate tag, and returns ErrorForm keyed by the json name of
+// the fields, or nil if all of them are valid. The rules are separated
+// by comma, and run in order until one fails:
+//     type Address struct {
+//         City string `json:"city" validate:"required"`
+//     }
+//     type User struct {
+//         Name    string    `json:"name" validate:"required,min=3"`
+//         Email   string    `json:"email" validate:"required,email"`
+//         Website string    `json:"website" validate:"omitempty,url"`
+//         Tags    []string  `json:"tags" validate:"max=5,dive,min=2"`
+//         Address Address   `json:"address"`
+//         Friends []Address `json:"friends"`
+//     }
+// Nested structs are validated as ErrorFormFieldNested, and slices of
+// structs as ErrorFormFieldArray. Rules after dive are run on each item
+// of the slice. Nil pointer is only checked by required, the other rules
+// are skipped. Zero value is checked by all rules, unless the rules have
+// omitempty. The built-in rules are required, omitempty, min, max, len,
+// email, url, oneof, and alphanum, more can be added by App.RegisterValidator.
+// The tags of a struct are parsed once. If they have unknown rule or rule
+// that can't be used for the type of the field, the error is logged and
+// ErrInternalServerError is returned.
+func Validate(obj interface{}) Error {
+	value := reflect.Indirect(reflect.ValueOf(obj))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	formErr := NewErrorForm()
+	if err := validateStruct(value, formErr.FieldError); err != nil {
+		App.Logger().Printf("validation: %v", err)
+		return ErrInternalServerError
+	}
+	if !formErr.IsError() {
+		return nil
+	}
+	return formErr
+}
+
+// structValidation is the parsed validate tags of a struct field
+type structValidation struct {
+	index    int
+	name     string
+	embedded bool
+	field    *fieldValidation
+}
+
+// fieldValidation is the parsed rules of a validate tag, items is
+// the rules after dive
+type fieldValidation struct {
+	required  bool
+	omitEmpty bool
+	rules     []validationRule
+	items     *fieldValidation
+}
+
+type validationRule struct {
+	name      string
+	param     string
+	validator Validator
+}
+
+// parsedValidations is the cached result of parsing the tags of a struct
+type parsedValidations struct {
+	fields []structValidation
+	err    error
+}
+
+// structValidations returns the parsed validate tags of the struct type,
+// or the error of parsing them. Both are cached until a validator is
+// registered, so invalid tags are not parsed on every request.
+func (app *Helios) structValidations(structType reflect.Type) ([]structValidation, error) {
+	app.validationMutex.RLock()
+	parsed, ok := app.validations[structType]
+	app.validationMutex.RUnlock()
+	if ok {
+		return parsed.fields, parsed.err
+	}
+
+	fields, err := app.parseStructValidations(structType)
+	app.validationMutex.Lock()
+	if app.validations == nil {
+		app.validations = make(map[reflect.Type]parsedValidations)
+	}
+	app.validations[structType] = parsedValidations{fields: fields, err: err}
+	app.validationMutex.Unlock()
+	return fields, err
+}
+
+func (app *Helios) parseStructValidations(structType reflect.Type) ([]structValidation, error) {
+	var fields []structValidation
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		embedded := field.Anonymous && field.Tag.Get("json") == "" && fieldType.Kind() == reflect.Struct
+		if field.PkgPath != "" && !embedded {
+			continue
+		}
+		validation, err := app.parseFieldValidation(field.Type, field.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag of %s.%s: %v", structType, field.Name, err)
+		}
+		fields = append(fields, structValidation{index: i, name: name, embedded: embedded, field: validation})
+	}
+	return fields, nil
+}
+
+// parseFieldValidation parses the rules in tag and checks them against
+// the type of the value that will be validated
+func (app *Helios) parseFieldValidation(valueType reflect.Type, tag string) (*fieldValidation, error) {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	validation := &fieldValidation{}
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		switch rule {
+		case "":
+			continue
+		case "required":
+			validation.required = true
+			continue
+		case "omitempty":
+			validation.omitEmpty = true
+			continue
+		case "dive":
+			if valueType.Kind() != reflect.Slice && valueType.Kind() != reflect.Array {
+				return nil, fmt.Errorf("can't dive into %s", valueType)
+			}
+			items, err := app.parseFieldValidation(valueType.Elem(), strings.Join(rules[i+1:], ","))
+			if err != nil {
+				return nil, err
+			}
+			validation.items = items
+			return validation, nil
+		}
+
+		name, param := rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+		validator, builtin := app.validator(name)
+		if validator == nil {
+			return nil, fmt.Errorf("unknown validator %q", name)
+		}
+		if builtin && sizeValidators[name] {
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return nil, fmt.Errorf("invalid param %q of validator %q", param, name)
+			}
+			if !hasSize(valueType) {
+				return nil, fmt.Errorf("can't compare size of %s", valueType)
+			}
+		}
+		validation.rules = append(validation.rules, validationRule{name: name, param: param, validator: validator})
+	}
+	return validation, nil
+}
+
+// validateStruct validates the fields of value and puts their error
+// in fieldErr, fields of embedded struct are put in the same level.
+// It returns error if the validate tags are invalid.
+func validateStruct(value reflect.Value, fieldErr ErrorFormFieldNested) error {
+	fields, err := App.structValidations(value.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldValue := value.Field(field.index)
+		if field.embedded {
+			if embedded := reflect.Indirect(fieldValue); embedded.IsValid() {
+				if err := validateStruct(embedded, fieldErr); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		formErr, err := validateField(fieldValue, field.field)
+		if err != nil {
+			return err
+		}
+		if formErr != nil && formErr.IsError() {
+			fieldErr[field.name] = formErr
+		}
+	}
+	return nil
+}
+
+// jsonFieldName returns the name of field in json,
+// or false if the field is not encoded to json
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+func validateField(value reflect.Value, validation *fieldValidation) (ErrorFormField, error) {
+	isNil := !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil())
+	isEmpty := isNil || value.IsZero() || ((value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0)
+	if validation.required && isEmpty {
+		return ErrorFormFieldAtomic{"this field is required"}, nil
+	}
+	if isNil || (validation.omitEmpty && isEmpty) {
+		return nil, nil
+	}
+
+	value = reflect.Indirect(value)
+	for _, rule := range validation.rules {
+		if err := rule.validator(value.Interface(), rule.param); err != nil {
+			return ErrorFormFieldAtomic{err.Error()}, nil
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		nested := make(ErrorFormFieldNested)
+		if err := validateStruct(value, nested); err != nil {
+			return nil, err
+		}
+		return nested, nil
+	case reflect.Slice, reflect.Array:
+		items := validation.items
+		if items == nil {
+			items = &fieldValidation{}
+		}
+		errs := make(ErrorFormFieldArray, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			item, err := validateField(value.Index(i), items)
+			if err != nil {
+				return nil, err
+			}
+			if item == nil || !item.IsError() {
+				item = ErrorFormFieldAtomic{}
+			}
+			errs[i] = item
+		}
+		return errs, nil
+	}
+	return nil, nil
+}
+
+var builtinValidators = map[string]Validator{
+	"min":      validateMin,
+	"max":      validateMax,
+	"len":      validateLen,
+	"email":    validateEmail,
+	"url":      validateURL,
+	"oneof":    validateOneOf,
+	"alphanum": validateAlphanum,
+}
+
+// sizeValidators are the built-in validators that compare size of value
+var sizeValidators = map[string]bool{"min": true, "max": true, "len": true}
+
+// size returns the length of string, slice, or map, or the number itself,
+// with the unit to be shown in the message
+func size(value interface{}) (float64, string) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	return 0, ""
+}
+
+// hasSize returns whether the size of value with the type can be compared
+func hasSize(valueType reflect.Type) bool {
+	switch valueType.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+func sizeParam(value interface{}, param string) (float64, float64, string) {
+	limit, _ := strconv.ParseFloat(param, 64)
+	n, unit := size(value)
+	return n, limit, unit
+}
+
+func validateMin(value interface{}, param string) error {
+	n, limit, unit := sizeParam(value, param)
+	if n < limit {
+		return fmt.Errorf("must be at least %s%s", param, unit)
+	}
+	return nil
+}
+
+func validateMax(value interface{}, param string) error {
+	n, limit, unit := sizeParam(value, param)
+	if n > limit {
+		return fmt.Errorf("must be at most %s%s", param, unit)
+	}
+	return nil
+}
+
+func validateLen(value interface{}, param string) error {
+	n, limit, unit := sizeParam(value, param)
+	if n != limit {
+		return fmt.Errorf("must be exactly %s%s", param, unit)
+	}
+	return nil
+}
+
+func validateEmail(value interface{}, param string) error {
+	address, err := mail.ParseAddress(fmt.Sprint(value))
+	if err != nil || address.Address != fmt.Sprint(value) {
+		return errors.New("must be a valid email address")
+	}
+	return nil
+}
+
+func validateURL(value interface{}, param string) error {
+	u, err := url.Parse(fmt.Sprint(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("must be a valid url")
+	}
+	return nil
+}
+
+func validateOneOf(value interface{}, param string) error {
+	s := fmt.Sprint(value)
+	options := strings.Fields(param)
+	for _, option := range options {
+		if s == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+}
+
+func validateAlphanum(value interface{}, param string) error {
+	for _, c := range fmt.Sprint(value) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return errors.New("must only contain letters and numbers")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package helios
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validationTestAddress struct {
+	City    string `json:"city" validate:"required"`
+	ZipCode string `json:"zipCode" validate:"omitempty,len=5"`
+}
+
+type validationTestBase struct {
+	ID int `json:"id" validate:"min=1"`
+}
+
+type validationTestUser struct {
+	validationTestBase
+	Name     string                  `json:"name" validate:"required,min=3,max=10"`
+	Email    string                  `json:"email" validate:"required,email"`
+	Website  string                  `json:"website,omitempty" validate:"omitempty,url"`
+	Role     string                  `json:"role" validate:"oneof=admin member"`
+	Age      *int                    `json:"age" validate:"required,min=17"`
+	Tags     []string                `json:"tags" validate:"max=3,dive,alphanum"`
+	Address  validationTestAddress   `json:"address"`
+	Friends  []validationTestAddress `json:"friends"`
+	Nickname string                  `validate:"even"`
+	Ignored  string                  `json:"-" validate:"required"`
+	ignored  string                  // nolint:unused,structcheck
+}
+
+func TestValidate(t *testing.T) {
+	App.BeforeTest()
+
+	App.RegisterValidator("even", func(value interface{}, param string) error {
+		if len(value.(string))%2 != 0 {
+			return errors.New("must have even length")
+		}
+		return nil
+	})
+	defer func() { App.validators = nil }()
+
+	age := 20
+	valid := validationTestUser{
+		validationTestBase: validationTestBase{ID: 1},
+		Name:               "helios",
+		Email:              "helios@example.com",
+		Role:               "admin",
+		Age:                &age,
+		Tags:               []string{"go"},
+		Address:            validationTestAddress{City: "Jakarta"},
+		Friends:            []validationTestAddress{{City: "Bandung"}},
+	}
+	assert.Nil(t, Validate(&valid), "Valid struct should return nil")
+	assert.Nil(t, Validate(valid), "Struct that is not pointer can also be validated")
+	assert.Nil(t, Validate(1), "Non struct should not be validated")
+
+	young := 12
+	invalid := validationTestUser{
+		validationTestBase: validationTestBase{ID: -1},
+		Name:               "he",
+		Email:              "not an email",
+		Website:            "example",
+		Role:               "owner",
+		Age:                &young,
+		Tags:               []string{"go", "c++", "rust", "java"},
+		Address:            validationTestAddress{ZipCode: "123"},
+		Friends:            []validationTestAddress{{City: "Bandung"}, {}},
+		Nickname:           "abc",
+	}
+	err := Validate(&invalid)
+	assert.NotNil(t, err, "Invalid struct should return error")
+	assert.Equal(t, http.StatusBadRequest, err.GetStatusCode(), "Validation error should be bad request")
+	message, _ := json.Marshal(err.GetMessage())
+	expected := `{"code":"form_error","message":{` +
+		`"Nickname":["must have even length"],` +
+		`"_error":[],` +
+		`"address":{"city":["this field is required"],"zipCode":["must be exactly 5 characters"]},` +
+		`"age":["must be at least 17"],` +
+		`"email":["must be a valid email address"],` +
+		`"friends":[[],{"city":["this field is required"]}],` +
+		`"id":["must be at least 1"],` +
+		`"name":["must be at least 3 characters"],` +
+		`"role":["must be one of admin, member"],` +
+		`"tags":["must be at most 3 items"],` +
+		`"website":["must be a valid url"]}}`
+	assert.Equal(t, expected, string(message), "Different validation error")
+
+	invalid = valid
+	invalid.Age = nil
+	invalid.Tags = []string{"go", "c++"}
+	message, _ = json.Marshal(Validate(&invalid).GetMessage())
+	assert.Equal(t, `{"code":"form_error","message":{"_error":[],"age":["this field is required"],"tags":[[],["must only contain letters and numbers"]]}}`, string(message), "Different validation error")
+
+	invalidTag := &struct {
+		A string `validate:"unknown"`
+	}{A: "a"}
+	App.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer App.SetLogger(nil)
+	assert.Equal(t, ErrInternalServerError, Validate(invalidTag), "Unknown validator should be internal server error")
+}
+
+func TestValidateZeroValue(t *testing.T) {
+	App.BeforeTest()
+
+	type zeroValue struct {
+		Count    int     `json:"count" validate:"min=1"`
+		Role     string  `json:"role" validate:"oneof=admin member"`
+		Optional string  `json:"optional" validate:"omitempty,oneof=admin member"`
+		Pointer  *int    `json:"pointer" validate:"min=1"`
+		Zero     *int    `json:"zero" validate:"min=1"`
+		Items    []int   `json:"items" validate:"omitempty,dive,min=1"`
+		Ratio    float64 `json:"ratio" validate:"omitempty,max=1"`
+	}
+	zero := 0
+	err := Validate(&zeroValue{Zero: &zero})
+	assert.NotNil(t, err, "Zero value should be validated")
+	expected := ErrorFormFieldNested{
+		"count": ErrorFormFieldAtomic{"must be at least 1"},
+		"role":  ErrorFormFieldAtomic{"must be one of admin, member"},
+		"zero":  ErrorFormFieldAtomic{"must be at least 1"},
+	}
+	assert.Equal(t, expected, err.(ErrorForm).FieldError, "Only nil pointer and omitempty should skip the rules")
+
+	one := 1
+	assert.Nil(t, Validate(&zeroValue{Count: 1, Role: "admin", Zero: &one}), "Valid zero value should return nil")
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	App.BeforeTest()
+	defer func() { App.validators = nil; App.validations = nil }()
+	var logs bytes.Buffer
+	App.SetLogger(log.New(&logs, "", 0))
+	defer App.SetLogger(nil)
+
+	testCases := []interface{}{
+		&struct {
+			A bool `validate:"min=1"`
+		}{},
+		&struct {
+			A string `validate:"max=x"`
+		}{},
+		&struct {
+			A string `validate:"dive,min=1"`
+		}{},
+		&struct {
+			A []bool `validate:"dive,len=1"`
+		}{},
+		&struct {
+			A string `validate:"omitempty,odd"`
+		}{},
+	}
+	for i, testCase := range testCases {
+		logs.Reset()
+		assert.Equal(t, ErrInternalServerError, Validate(testCase), "Invalid tag should be error even if the value is empty on test case %d", i)
+		assert.Contains(t, logs.String(), "invalid validate tag of", "Invalid tag should be logged on test case %d", i)
+		assert.NotNil(t, App.validations[reflect.TypeOf(testCase).Elem()].err, "Invalid tag should be cached on test case %d", i)
+	}
+
+	type nested struct {
+		Items []struct {
+			A string `json:"a" validate:"gte=1"`
+		} `json:"items"`
+	}
+	recorder := httptest.NewRecorder()
+	Handle(HandleE(func(req Request) Error {
+		var data nested
+		return req.DeserializeRequestData(&data)
+	}))(recorder, httptest.NewRequest("POST", "/", strings.NewReader(`{"items":[{"a":"1"}]}`)))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "Invalid tag of nested struct should be internal server error")
+
+	App.RegisterValidator("odd", func(value interface{}, param string) error {
+		return nil
+	})
+	assert.Nil(t, Validate(testCases[4]), "Registered validator should be found after the tag is parsed")
+}
+
+func TestDeserializeRequestDataValidation(t *testing.T) {
+	App.BeforeTest()
+
+	var user validationTestAddress
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/", strings.NewReader(`{"zipCode":"123"}`))
+	req := NewHTTPRequest(recorder, request)
+	err := req.DeserializeRequestData(&user)
+	assert.NotNil(t, err, "Invalid request data should return error")
+	assert.Equal(t, ErrorFormFieldAtomic{"this field is required"}, err.(ErrorForm).FieldError["city"], "Different error of city")
+
+	mockReq := NewMockRequest()
+	mockReq.RequestData = validationTestAddress{City: "Jakarta", ZipCode: "12345"}
+	assert.Nil(t, mockReq.DeserializeRequestData(&user), "Valid request data should not return error")
+	mockReq.RequestData = `{"city":"Jakarta","zipCode":"1"}`
+	err = mockReq.DeserializeRequestData(&user)
+	assert.NotNil(t, err, "Invalid request data should return error")
+	assert.Equal(t, ErrorFormFieldAtomic{"must be exactly 5 characters"}, err.(ErrorForm).FieldError["zipCode"], "Different error of zip code")
+}
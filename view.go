@@ -1,61 +1,122 @@
 package helios
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/gorilla/mux"
-	"github.com/gorilla/sessions"
+	"github.com/jinzhu/gorm"
 )
 
 // Request interface of Helios Http Request Wrapper
 type Request interface {
 	DeserializeRequestData(obj interface{}) Error
+	GetFile(key string) (*UploadedFile, Error)
+	GetFormValue(key string) string
 
 	GetURLParam(key string) string
 	GetURLParamUint(key string) (uint, error)
+	GetURLParamInt64(key string) (int64, error)
+	GetURLParamUint64(key string) (uint64, error)
+	GetURLParamUUID(key string) (UUID, error)
+	GetURLParamTime(key string, layout string) (time.Time, error)
+
+	GetQueryParam(key string) string
+	GetQueryParams(key string) []string
+	GetQueryParamInt(key string) (int, error)
+	GetQueryParamUint64(key string) (uint64, error)
+	GetQueryParamBool(key string) (bool, error)
+	GetQueryParamTime(key string, layout string) (time.Time, error)
+	BindQuery(obj interface{}) Error
 
 	GetContextData(key string) interface{}
 	SetContextData(key string, value interface{})
 
 	GetSessionData(key string) interface{}
 	SetSessionData(key string, value interface{})
-	SaveSession()
+	SaveSession() error
+	RegenerateSession()
+	DestroySession()
 
 	ClientIP() string
+	RemoteIP() string
+	GetMethod() string
+	GetPath() string
+
+	DB() *gorm.DB
+	GetDB(name string) *gorm.DB
+	GetReadDB() *gorm.DB
+	GetWriteDB() *gorm.DB
 
 	GetHeader(key string) string
 	SetHeader(key string, value string)
+	AddHeader(key string, value string)
 
+	Send(output interface{}, code int)
 	SendJSON(output interface{}, code int)
+	SendError(err Error)
+	SendStatus(code int)
 }
 
 // HTTPHandler receive Helios wrapped request and ressponse
 type HTTPHandler func(Request)
 
+// HTTPHandlerE is HTTPHandler that may return Error instead of sending it.
+// It can be used as HTTPHandler by wrapping it with HandleE.
+type HTTPHandlerE func(Request) Error
+
+// HandleE converts HTTPHandlerE to HTTPHandler. The returned Error is sent
+// with req.SendError. Other errors can be returned with ToError, which
+// logs them and returns ErrInternalServerError.
+//     App.POST("/users", HandleE(func(req Request) Error {
+//         var user User
+//         if err := req.DeserializeRequestData(&user); err != nil {
+//             return err
+//         }
+//         if err := req.DB().Create(&user).Error; err != nil {
+//             return ToError(err)
+//         }
+//         req.SendJSON(user, http.StatusCreated)
+//         return nil
+//     }))
+func HandleE(f HTTPHandlerE) HTTPHandler {
+	return func(req Request) {
+		if err := f(req); err != nil {
+			req.SendError(err)
+		}
+	}
+}
+
 // Handle the http request using the HTTPHandler, without middleware
+// other than RecoveryMiddleware
 func Handle(f HTTPHandler) func(http.ResponseWriter, *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req HTTPRequest = NewHTTPRequest(w, r)
-		f(&req)
+		defer req.removeUploadedFiles()
+		RecoveryMiddleware(f)(&req)
 	})
 }
 
 // HTTPRequest wrapper of Helios Http Request
 // r is the HTTP Request, containing request data
 // w is the HTTP Response writer, to write HTTP reply
-// s is the session of current request, using gorilla/sessions package
+// s is the session of current request, loaded from App session store
 // c is the context of the current request, can be used for user data, etc
 // u is the url params argument
 type HTTPRequest struct {
 	r *http.Request
 	w http.ResponseWriter
-	s *sessions.Session
+	s *Session
 	c map[string]interface{}
 	u map[string]string
 }
@@ -67,7 +128,7 @@ func NewHTTPRequest(w http.ResponseWriter, r *http.Request) HTTPRequest {
 		w: w,
 		s: App.getSession(r),
 		c: make(map[string]interface{}),
-		u: mux.Vars(r),
+		u: App.extractURLParams(r),
 	}
 }
 
@@ -81,24 +142,57 @@ func (req *HTTPRequest) GetURLParamUint(key string) (uint, error) {
 	paramStr := req.u[key]
 	param64, errParseQuestionID := strconv.ParseUint(paramStr, 10, 32)
 	if errParseQuestionID != nil {
-		return uint(0), errors.New("Failed to parse param as uint")
+		return uint(0), ErrInvalidURLParam
 	}
 	return uint(param64), nil
 }
 
 // DeserializeRequestData deserializes the request body
-// and parse it into pointer to struct
+// and parse it into pointer to struct. The body can be
+// form-urlencoded, multipart/form-data, or any media type with
+// codec, see App.RegisterCodec. Form field is matched by form
+// tag, or json tag if there is no form tag. Body without
+// Content-Type is decoded as json.
 func (req *HTTPRequest) DeserializeRequestData(obj interface{}) Error {
 	contentType := req.r.Header.Get("Content-Type")
-	if contentType == "application/json" || contentType == "" {
-		decoder := json.NewDecoder(req.r.Body)
-		err := decoder.Decode(obj)
-		if err != nil {
-			return ErrJSONParseFailed
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err := req.parseForm(); err != nil {
+			return err
+		}
+		if err := decodeForm(req.r.PostForm, nil, obj); err != nil {
+			return err
+		}
+	case "multipart/form-data":
+		if err := req.parseMultipartForm(); err != nil {
+			return err
+		}
+		if err := decodeForm(req.r.MultipartForm.Value, req.uploadedFiles(), obj); err != nil {
+			return err
+		}
+	default:
+		if err := decodeBody(contentType, req.r.Body, obj); err != nil {
+			return err
 		}
-		return nil
 	}
-	return ErrUnsupportedContentType
+	return Validate(obj)
+}
+
+// decodeBody decodes body with the codec of the content type
+func decodeBody(contentType string, body io.Reader, obj interface{}) Error {
+	codec, _, ok := App.codecForContentType(contentType)
+	if !ok {
+		return ErrUnsupportedContentType
+	}
+	if err := codec.Decode(body, obj); err != nil {
+		var heliosErr Error
+		if errors.As(err, &heliosErr) {
+			return heliosErr
+		}
+		return ErrRequestParseFailed
+	}
+	return nil
 }
 
 // GetSessionData return the data of session with known key
@@ -121,21 +215,110 @@ func (req *HTTPRequest) SetContextData(key string, value interface{}) {
 	req.c[key] = value
 }
 
-// SaveSession saves the session to the cookie
-func (req *HTTPRequest) SaveSession() {
-	req.s.Save(req.r, req.w) // nolint:errcheck
+// SaveSession saves the session using App session store
+func (req *HTTPRequest) SaveSession() error {
+	if App.store != nil {
+		return App.store.Save(req.w, req.r, req.s)
+	}
+	return nil
+}
+
+// RegenerateSession gives the session new ID, keeping the data.
+// It has to be followed by SaveSession.
+func (req *HTTPRequest) RegenerateSession() {
+	req.s.Regenerate()
+}
+
+// DestroySession removes the session data and expires the session cookie
+func (req *HTTPRequest) DestroySession() {
+	if App.store != nil {
+		App.store.Destroy(req.w, req.r, req.s) // nolint:errcheck
+	} else {
+		req.s.Values = make(map[string]interface{})
+	}
+}
+
+// DB returns the database handle of the request, that is the transaction
+// if the handler is wrapped by TransactionMiddleware, or App write database
+func (req *HTTPRequest) DB() *gorm.DB {
+	return App.WriteDB()
+}
+
+// GetDB returns the database registered in App with the name
+func (req *HTTPRequest) GetDB(name string) *gorm.DB {
+	return App.Database(name)
+}
+
+// GetReadDB returns the database for reading, chosen by App's database router
+func (req *HTTPRequest) GetReadDB() *gorm.DB {
+	return App.ReadDB()
+}
+
+// GetWriteDB returns the database for writing, chosen by App's database router
+func (req *HTTPRequest) GetWriteDB() *gorm.DB {
+	return App.WriteDB()
 }
 
 // GetHeader gets the header of request
 func (req *HTTPRequest) GetHeader(key string) string {
+	// net/http moves Host header out of the header map
+	if strings.EqualFold(key, "Host") {
+		return req.r.Host
+	}
 	return req.r.Header.Get(key)
 }
 
+// GetMethod returns the http method of request
+func (req *HTTPRequest) GetMethod() string {
+	return req.r.Method
+}
+
+// GetPath returns the path of request url
+func (req *HTTPRequest) GetPath() string {
+	return req.r.URL.Path
+}
+
 // SetHeader sets the header of response writer
 func (req *HTTPRequest) SetHeader(key string, value string) {
 	req.w.Header().Set(key, value)
 }
 
+// AddHeader adds the value to the header of response writer,
+// keeping the values that are already set
+func (req *HTTPRequest) AddHeader(key string, value string) {
+	req.w.Header().Add(key, value)
+}
+
+// Send write output as http response, encoded by the codec chosen from
+// Accept header of the request. ErrNotAcceptable is sent as json if none
+// of the accepted media types has codec.
+func (req *HTTPRequest) Send(output interface{}, code int) {
+	response, mediaType, err := encodeResponse(req.r.Header.Get("Accept"), output)
+	if err != nil {
+		req.SendError(err)
+		return
+	}
+
+	req.w.Header().Set("Content-Type", mediaType)
+	req.w.Header().Add("Vary", "Accept")
+	req.w.WriteHeader(code)
+	req.w.Write(response) // nolint:errcheck
+}
+
+// encodeResponse encodes output with the codec negotiated from accept
+func encodeResponse(accept string, output interface{}) ([]byte, string, Error) {
+	codec, mediaType, ok := App.negotiateCodec(accept)
+	if !ok {
+		return nil, "", ErrNotAcceptable
+	}
+	var response bytes.Buffer
+	if err := codec.Encode(&response, output); err != nil {
+		App.Logger().Printf("failed to encode response as %s: %v", mediaType, err)
+		return nil, "", ErrInternalServerError
+	}
+	return response.Bytes(), mediaType, nil
+}
+
 // SendJSON write json as http response
 func (req *HTTPRequest) SendJSON(output interface{}, code int) {
 	response, _ := json.Marshal(output)
@@ -145,6 +328,42 @@ func (req *HTTPRequest) SendJSON(output interface{}, code int) {
 	req.w.Write(response) // nolint:errcheck
 }
 
+// SendError write the message and status code of err as http response,
+// encoded by the codec chosen from Accept header like Send. It is sent
+// as json if none of the accepted codecs can encode it, e.g. xml can't
+// encode the message map, so ErrNotAcceptable can be sent too.
+func (req *HTTPRequest) SendError(err Error) {
+	response, mediaType, ok := encodeError(req.r.Header.Get("Accept"), err)
+	if !ok {
+		req.SendJSON(err.GetMessage(), err.GetStatusCode())
+		return
+	}
+	req.w.Header().Set("Content-Type", mediaType)
+	req.w.Header().Add("Vary", "Accept")
+	req.w.WriteHeader(err.GetStatusCode())
+	req.w.Write(response) // nolint:errcheck
+}
+
+// encodeError encodes the message of err with the codec negotiated
+// from accept, or returns false if it can't be encoded
+func encodeError(accept string, err Error) ([]byte, string, bool) {
+	codec, mediaType, ok := App.negotiateCodec(accept)
+	if !ok {
+		return nil, "", false
+	}
+	var response bytes.Buffer
+	if codec.Encode(&response, err.GetMessage()) != nil {
+		return nil, "", false
+	}
+	return response.Bytes(), mediaType, true
+}
+
+// SendStatus write the status code as http response without body,
+// e.g. http.StatusNoContent
+func (req *HTTPRequest) SendStatus(code int) {
+	req.w.WriteHeader(code)
+}
+
 // ClientIP returns the original ip address of the request.
 // First, it checks for X-Forwarded-For and X-Real-Ip http header
 // (https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/X-Forwarded-For)
@@ -167,30 +386,105 @@ func (req *HTTPRequest) ClientIP() string {
 	return ""
 }
 
+// RemoteIP returns the ip address of the peer, which can't be spoofed by the
+// client unlike ClientIP. If the peer is a proxy set by App.SetTrustedProxies,
+// it returns the rightmost X-Forwarded-For entry that is not a trusted proxy.
+func (req *HTTPRequest) RemoteIP() string {
+	return App.remoteIP(req.r.RemoteAddr, req.r.Header.Get("X-Forwarded-For"))
+}
+
+// SetTrustedProxies sets the ip addresses or CIDR ranges of the reverse
+// proxies in front of the app, whose X-Forwarded-For is used by RemoteIP
+//     helios.App.SetTrustedProxies("10.0.0.0/8", "127.0.0.1")
+func (app *Helios) SetTrustedProxies(proxies ...string) error {
+	var trusted []*net.IPNet
+	for _, proxy := range proxies {
+		cidr := proxy
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		trusted = append(trusted, network)
+	}
+	app.trustedProxies = trusted
+	return nil
+}
+
+func (app *Helios) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range app.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the ip of remoteAddr, or the rightmost untrusted hop of
+// forwardedFor if remoteAddr is a trusted proxy
+func (app *Helios) remoteIP(remoteAddr string, forwardedFor string) string {
+	ip := strings.TrimSpace(remoteAddr)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !app.isTrustedProxy(ip) || forwardedFor == "" {
+		return ip
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = strings.TrimSpace(hops[i])
+		if !app.isTrustedProxy(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
 // MockRequest is Request object that is mocked for testing purposes
 type MockRequest struct {
-	RequestData    interface{}
-	RequestHeader  map[string]string
-	ResponseHeader map[string]string
-	SessionData    map[string]interface{}
-	ContextData    map[string]interface{}
-	JSONResponse   []byte
-	StatusCode     int
-	URLParam       map[string]string
-	RemoteAddr     string
+	RequestData        interface{}
+	Files              map[string][]*UploadedFile
+	RequestHeader      map[string]string
+	ResponseHeader     map[string]string
+	SessionData        map[string]interface{}
+	SessionRegenerated bool
+	SessionDestroyed   bool
+	ContextData        map[string]interface{}
+	JSONResponse       []byte
+	Response           []byte
+	StatusCode         int
+	URLParam           map[string]string
+	QueryParam         url.Values
+	RemoteAddr         string
+	Method             string
+	Path               string
 }
 
 // NewMockRequest returns new MockRequest with empty data
-// RemoteAddr is set to 127.0.0.1 in default
+// RemoteAddr is set to 127.0.0.1, Method to GET, and Path to / in default
 func NewMockRequest() MockRequest {
 	return MockRequest{
 		SessionData:    make(map[string]interface{}),
 		RequestData:    make(map[string]string),
+		Files:          make(map[string][]*UploadedFile),
 		RequestHeader:  make(map[string]string),
 		ResponseHeader: make(map[string]string),
 		ContextData:    make(map[string]interface{}),
 		URLParam:       make(map[string]string),
+		QueryParam:     make(url.Values),
 		RemoteAddr:     "127.0.0.1",
+		Method:         http.MethodGet,
+		Path:           "/",
 	}
 }
 
@@ -204,30 +498,44 @@ func (req *MockRequest) GetURLParamUint(key string) (uint, error) {
 	paramStr := req.URLParam[key]
 	param64, errParseQuestionID := strconv.ParseUint(paramStr, 10, 32)
 	if errParseQuestionID != nil {
-		return uint(0), errors.New("Failed to parse param as uint")
+		return uint(0), ErrInvalidURLParam
 	}
 	return uint(param64), nil
 }
 
-// DeserializeRequestData return the data of request
+// DeserializeRequestData return the data of request. RequestData can be
+// string or []byte decoded by the codec of Content-Type in RequestHeader
+// (json if it is empty), url.Values to simulate form request along with
+// Files, or a struct of the same type as obj.
 func (req *MockRequest) DeserializeRequestData(obj interface{}) Error {
 	if req.RequestData == nil {
 		return ErrUnsupportedContentType
 	}
 
-	requestBody, ok := req.RequestData.(string)
-	if ok {
-		decoder := json.NewDecoder(strings.NewReader(requestBody))
-		err := decoder.Decode(obj)
-		if err != nil {
-			return ErrJSONParseFailed
+	if values, ok := req.RequestData.(url.Values); ok {
+		if err := decodeForm(values, req.Files, obj); err != nil {
+			return err
 		}
-		return nil
+		return Validate(obj)
+	}
+
+	var body io.Reader
+	switch requestBody := req.RequestData.(type) {
+	case string:
+		body = strings.NewReader(requestBody)
+	case []byte:
+		body = bytes.NewReader(requestBody)
+	}
+	if body != nil {
+		if err := decodeBody(req.GetHeader("Content-Type"), body, obj); err != nil {
+			return err
+		}
+		return Validate(obj)
 	}
 
 	result := reflect.ValueOf(obj).Elem()
 	result.Set(reflect.ValueOf(req.RequestData))
-	return nil
+	return Validate(obj)
 }
 
 // GetSessionData return the data of session with known key
@@ -251,8 +559,40 @@ func (req *MockRequest) SetContextData(key string, value interface{}) {
 }
 
 // SaveSession do nothing because the session is already saved
-func (req *MockRequest) SaveSession() {
-	//
+func (req *MockRequest) SaveSession() error {
+	return nil
+}
+
+// RegenerateSession marks the session as regenerated
+func (req *MockRequest) RegenerateSession() {
+	req.SessionRegenerated = true
+}
+
+// DestroySession clears the session data and marks it as destroyed
+func (req *MockRequest) DestroySession() {
+	req.SessionData = make(map[string]interface{})
+	req.SessionDestroyed = true
+}
+
+// DB returns the database handle of the request, that is the transaction
+// if the handler is wrapped by TransactionMiddleware, or App write database
+func (req *MockRequest) DB() *gorm.DB {
+	return App.WriteDB()
+}
+
+// GetDB returns the database registered in App with the name
+func (req *MockRequest) GetDB(name string) *gorm.DB {
+	return App.Database(name)
+}
+
+// GetReadDB returns the database for reading, chosen by App's database router
+func (req *MockRequest) GetReadDB() *gorm.DB {
+	return App.ReadDB()
+}
+
+// GetWriteDB returns the database for writing, chosen by App's database router
+func (req *MockRequest) GetWriteDB() *gorm.DB {
+	return App.WriteDB()
 }
 
 // GetHeader gets the header of request
@@ -265,6 +605,27 @@ func (req *MockRequest) SetHeader(key string, value string) {
 	req.ResponseHeader[key] = value
 }
 
+// AddHeader appends the value to ResponseHeader, separated by comma
+func (req *MockRequest) AddHeader(key string, value string) {
+	if current := req.ResponseHeader[key]; current != "" {
+		value = current + ", " + value
+	}
+	req.ResponseHeader[key] = value
+}
+
+// Send write output to Response, encoded by the codec chosen
+// from Accept in RequestHeader
+func (req *MockRequest) Send(output interface{}, code int) {
+	response, mediaType, err := encodeResponse(req.GetHeader("Accept"), output)
+	if err != nil {
+		req.SendError(err)
+		return
+	}
+	req.Response = response
+	req.ResponseHeader["Content-Type"] = mediaType
+	req.StatusCode = code
+}
+
 // SendJSON write json as http response
 func (req *MockRequest) SendJSON(output interface{}, code int) {
 	var err error
@@ -276,7 +637,42 @@ func (req *MockRequest) SendJSON(output interface{}, code int) {
 	}
 }
 
+// SendError write the message and status code of err to JSONResponse,
+// or to Response if Accept in RequestHeader negotiates other codec
+// than json that can encode it
+func (req *MockRequest) SendError(err Error) {
+	response, mediaType, ok := encodeError(req.GetHeader("Accept"), err)
+	if !ok || mediaType == "application/json" {
+		req.SendJSON(err.GetMessage(), err.GetStatusCode())
+		return
+	}
+	req.Response = response
+	req.ResponseHeader["Content-Type"] = mediaType
+	req.StatusCode = err.GetStatusCode()
+}
+
+// SendStatus sets the status code without response body
+func (req *MockRequest) SendStatus(code int) {
+	req.StatusCode = code
+}
+
 // ClientIP returns RemoteAddr data of req
 func (req *MockRequest) ClientIP() string {
 	return req.RemoteAddr
 }
+
+// RemoteIP returns RemoteAddr data of req, or the X-Forwarded-For
+// request header if RemoteAddr is a trusted proxy
+func (req *MockRequest) RemoteIP() string {
+	return App.remoteIP(req.RemoteAddr, req.GetHeader("X-Forwarded-For"))
+}
+
+// GetMethod returns Method data of req
+func (req *MockRequest) GetMethod() string {
+	return req.Method
+}
+
+// GetPath returns Path data of req
+func (req *MockRequest) GetPath() string {
+	return req.Path
+}
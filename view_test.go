@@ -1,6 +1,9 @@
 package helios
 
 import (
+	"bytes"
+	"errors"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -80,7 +83,7 @@ func TestMockRequest(t *testing.T) {
 
 	req.RequestData = "{\"b\":\"def\"}"
 	errDeserialize4 := req.DeserializeRequestData(&actual)
-	assert.Equal(t, ErrJSONParseFailed, errDeserialize4, "Failed to deserialize request data")
+	assert.Equal(t, ErrorFormFieldAtomic{"must be an integer"}, errDeserialize4.(ErrorForm).FieldError["b"], "Type mismatch should be returned as form error")
 
 	req.RequestData = "{\"a\":\"def"
 	errDeserialize5 := req.DeserializeRequestData(&actual)
@@ -241,8 +244,8 @@ func TestHTTPRequestJSONPoorlyEncoded(t *testing.T) {
 func TestHTTPRequestUrlFormEncoded(t *testing.T) {
 	App.BeforeTest()
 
-	request, _ := http.NewRequest("POST", "/def", strings.NewReader("a=abcde&b=2&c=true}"))
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request, _ := http.NewRequest("POST", "/def", strings.NewReader("a=abcde&b=2&c=true"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
 	recorder := httptest.NewRecorder()
 	req := HTTPRequest{
 		r: request,
@@ -255,29 +258,25 @@ func TestHTTPRequestUrlFormEncoded(t *testing.T) {
 	var requestData sampleRequest
 
 	err := req.DeserializeRequestData(&requestData)
-	assert.Equal(t, ErrUnsupportedContentType, err, "application/x-www-form-urlencoded is not supported yet")
+	assert.Nil(t, err, "Failed to deserialize form-urlencoded request")
+	assert.Equal(t, sampleRequest{A: "abcde", B: 2, C: true}, requestData, "Different request data")
 }
 
 func TestHTTPRequestMultipartFormData(t *testing.T) {
 	App.BeforeTest()
 
-	request, _ := http.NewRequest("POST", "/def", strings.NewReader(`
-	-----------------------------9051914041544843365972754266
-	Content-Disposition: form-data; name="a"
-
-	abcde
-	-----------------------------9051914041544843365972754266
-	Content-Disposition: form-data; name="b"
-
-	2
-	-----------------------------9051914041544843365972754266
-	Content-Disposition: form-data; name="c"
-
-	true
-
-	-----------------------------9051914041544843365972754266-
-	`))
-	request.Header.Set("Content-Type", "multipart/form-data")
+	body := "--helios-boundary\r\n" +
+		"Content-Disposition: form-data; name=\"a\"\r\n\r\n" +
+		"abcde\r\n" +
+		"--helios-boundary\r\n" +
+		"Content-Disposition: form-data; name=\"b\"\r\n\r\n" +
+		"2\r\n" +
+		"--helios-boundary\r\n" +
+		"Content-Disposition: form-data; name=\"c\"\r\n\r\n" +
+		"true\r\n" +
+		"--helios-boundary--\r\n"
+	request, _ := http.NewRequest("POST", "/def", strings.NewReader(body))
+	request.Header.Set("Content-Type", "multipart/form-data; boundary=helios-boundary")
 	recorder := httptest.NewRecorder()
 	req := HTTPRequest{
 		r: request,
@@ -290,7 +289,20 @@ func TestHTTPRequestMultipartFormData(t *testing.T) {
 	var requestData sampleRequest
 
 	err := req.DeserializeRequestData(&requestData)
-	assert.Equal(t, ErrUnsupportedContentType, err, "multipart/form-data is not supported yet")
+	assert.Nil(t, err, "Failed to deserialize multipart request")
+	assert.Equal(t, sampleRequest{A: "abcde", B: 2, C: true}, requestData, "Different request data")
+
+	request, _ = http.NewRequest("POST", "/def", strings.NewReader(body))
+	request.Header.Set("Content-Type", "multipart/form-data")
+	req.r = request
+	err = req.DeserializeRequestData(&requestData)
+	assert.Equal(t, ErrFormParseFailed, err, "Multipart request without boundary should return error")
+
+	request, _ = http.NewRequest("POST", "/def", strings.NewReader("<a>abcde</a>"))
+	request.Header.Set("Content-Type", "text/plain")
+	req.r = request
+	err = req.DeserializeRequestData(&requestData)
+	assert.Equal(t, ErrUnsupportedContentType, err, "text/plain is not supported")
 }
 
 func TestHTTPRequestClientIP(t *testing.T) {
@@ -356,3 +368,91 @@ func TestHTTPRequestClientIP(t *testing.T) {
 
 	assert.Empty(t, reqBadIP.ClientIP(), "Bad IP format on RemoteAddr will return empty ip")
 }
+
+func TestHTTPRequestRemoteIP(t *testing.T) {
+	App.BeforeTest()
+	defer App.SetTrustedProxies() // nolint:errcheck
+
+	type remoteIPTestCase struct {
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		expected       string
+	}
+	testCases := []remoteIPTestCase{
+		{remoteAddr: "55.66.77.88:12345", forwardedFor: "1.2.3.4", expected: "55.66.77.88"},
+		{remoteAddr: "55.66.77.88", expected: "55.66.77.88"},
+		{trustedProxies: []string{"55.66.77.88"}, remoteAddr: "55.66.77.88:12345", expected: "55.66.77.88"},
+		{trustedProxies: []string{"55.66.77.88"}, remoteAddr: "55.66.77.88:12345", forwardedFor: "1.2.3.4, 5.6.7.8", expected: "5.6.7.8"},
+		{trustedProxies: []string{"10.0.0.0/8", "55.66.77.88"}, remoteAddr: "55.66.77.88:12345", forwardedFor: "1.2.3.4, 5.6.7.8, 10.1.2.3", expected: "5.6.7.8"},
+		{trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "55.66.77.88:12345", forwardedFor: "1.2.3.4", expected: "55.66.77.88"},
+		{trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:12345", forwardedFor: "10.0.0.2, 10.0.0.3", expected: "10.0.0.2"},
+		{trustedProxies: []string{"::1"}, remoteAddr: "[::1]:12345", forwardedFor: "1.2.3.4", expected: "1.2.3.4"},
+	}
+	for i, testCase := range testCases {
+		assert.Nil(t, App.SetTrustedProxies(testCase.trustedProxies...), "Failed to set trusted proxies on test case %d", i)
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.RemoteAddr = testCase.remoteAddr
+		request.Header.Set("X-Forwarded-For", testCase.forwardedFor)
+		req := NewHTTPRequest(httptest.NewRecorder(), request)
+		assert.Equal(t, testCase.expected, req.RemoteIP(), "Different remote ip on test case %d", i)
+	}
+
+	assert.NotNil(t, App.SetTrustedProxies("proxy"), "Invalid proxy should return error")
+}
+
+func TestHandleE(t *testing.T) {
+	App.BeforeTest()
+
+	var logs bytes.Buffer
+	App.SetLogger(log.New(&logs, "", 0))
+	defer App.SetLogger(nil)
+
+	formErr := NewErrorForm()
+	formErr.FieldError["name"] = ErrorFormFieldAtomic{"name can't be empty"}
+	type handleETestCase struct {
+		err          error
+		expectedCode int
+		expectedBody string
+	}
+	testCases := []handleETestCase{{
+		err:          nil,
+		expectedCode: http.StatusOK,
+		expectedBody: "\"ok\"",
+	}, {
+		err:          ErrNotFound,
+		expectedCode: http.StatusNotFound,
+		expectedBody: "{\"code\":\"not_found\",\"message\":\"The requested url is not found\"}",
+	}, {
+		err:          formErr,
+		expectedCode: http.StatusBadRequest,
+		expectedBody: "{\"code\":\"form_error\",\"message\":{\"_error\":[],\"name\":[\"name can't be empty\"]}}",
+	}, {
+		err:          errors.New("connection refused"),
+		expectedCode: http.StatusInternalServerError,
+		expectedBody: "{\"code\":\"internal_server_error\",\"message\":\"Error occured while processing the request\"}",
+	}}
+	for i, testCase := range testCases {
+		err := testCase.err
+		f := HandleE(func(req Request) Error {
+			if err != nil {
+				return ToError(err)
+			}
+			req.SendJSON("ok", http.StatusOK)
+			return nil
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		Handle(f)(recorder, request)
+		assert.Equal(t, testCase.expectedCode, recorder.Code, "Different status code on test case %d", i)
+		assert.Equal(t, testCase.expectedBody, recorder.Body.String(), "Different body on test case %d", i)
+
+		req := NewMockRequest()
+		f(&req)
+		if err != nil {
+			assert.Equal(t, testCase.expectedCode, req.StatusCode, "Different status code of mock request on test case %d", i)
+			assert.Equal(t, testCase.expectedBody, string(req.JSONResponse), "Different body of mock request on test case %d", i)
+		}
+	}
+	assert.Contains(t, logs.String(), "connection refused", "Plain error should be logged")
+}